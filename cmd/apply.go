@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/PolarGeospatialCenter/inventory-cli/pkg/backup"
+	"github.com/PolarGeospatialCenter/inventory-cli/pkg/ingestlib"
+	"github.com/PolarGeospatialCenter/inventory-cli/pkg/manifest"
+	"github.com/PolarGeospatialCenter/inventory-cli/pkg/selector"
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+)
+
+var (
+	applyFiles    []string
+	applyDryRun   bool
+	applyOutput   string
+	applyPrune    bool
+	applySelector string
+	applyYes      bool
+)
+
+func init() {
+	cmdApply.Flags().StringArrayVarP(&applyFiles, "filename", "f", []string{}, "manifest file or directory of manifests to apply, - reads stdin, may be repeated")
+	cmdApply.MarkFlagRequired("filename")
+	cmdApply.Flags().BoolVar(&applyDryRun, "dry-run", false, "show the plan without changing anything")
+	cmdApply.Flags().StringVarP(&applyOutput, "output", "o", "text", "plan output format (text or json)")
+	cmdApply.Flags().BoolVar(&applyPrune, "prune", false, "delete live objects within --selector that aren't in the manifests")
+	cmdApply.Flags().StringVarP(&applySelector, "selector", "l", "", "nodes that may be deleted by --prune, along with the systems and networks they use, e.g. 'system=tst'")
+	cmdApply.Flags().BoolVar(&applyYes, "yes", false, "don't ask for confirmation before applying the plan")
+	addConcurrencyFlags(cmdApply.Flags())
+	rootCmd.AddCommand(cmdApply)
+}
+
+var cmdApply = &cobra.Command{
+	Use:   "apply -f filename",
+	Short: "create or update nodes, systems and networks to match manifests",
+	Long: `Create or update nodes, systems and networks to match manifests.
+
+Manifests are YAML or JSON files holding one or more objects, each with a kind
+of node, system or network and the same fields as the api.  Directories are
+read one level deep, every .yaml, .yml and .json file is applied.
+
+  kind: node
+  inventoryid: tst-0001
+  system: tst
+  role: compute
+  environment: prod
+  building: bldg
+  room: "30"
+  rack: aa01
+  bottomu: 12
+  networks:
+    prod:
+      nics: ["00:11:22:33:44:55"]
+
+Live objects that differ from the manifests are overwritten.  With --prune,
+live objects that aren't in any manifest are deleted if they are nodes matching
+--selector, or the systems and networks those nodes use.  Only the kinds the
+manifests contain are pruned, and systems and networks that remain in use
+aren't deleted.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var pruneScope backup.Filter
+		if applyPrune {
+			if applySelector == "" {
+				log.Fatalf("--prune requires a --selector to limit the nodes that may be deleted")
+			}
+			s, err := selector.Parse(applySelector)
+			if err != nil {
+				log.Fatalf("invalid selector: %v", err)
+			}
+			pruneScope.Selector = s
+		}
+
+		docs, err := manifest.ReadFiles(applyFiles)
+		if err != nil {
+			log.Fatalf("unable to read manifests: %v", err)
+		}
+		manifests, err := manifest.Backup(docs)
+		if err != nil {
+			log.Fatalf("invalid manifests: %v", err)
+		}
+
+		api, err := apiConnect()
+		if err != nil {
+			log.Fatalf("unable to connect to api: %v", err)
+		}
+
+		live, err := fetchInventory(api)
+		if err != nil {
+			log.Fatalf("unable to read current inventory: %v", err)
+		}
+
+		plan, err := manifest.Plan(manifests, live, applyPrune, pruneScope)
+		if err != nil {
+			log.Fatalf("unable to plan changes: %v", err)
+		}
+
+		refuseBlocked(plan, "apply")
+		validateAppliedNodes(plan, manifests, live)
+
+		printPlan(plan, applyOutput)
+		if applyDryRun {
+			return
+		}
+
+		counts := plan.Counts()
+		changes := counts[backup.ActionCreate] + counts[backup.ActionUpdate] + counts[backup.ActionDelete]
+		if changes == 0 {
+			return
+		}
+		if !applyYes {
+			prompt := promptui.Prompt{Label: fmt.Sprintf("Apply %d changes to the inventory?", changes), IsConfirm: true}
+			if _, err := prompt.Run(); err != nil {
+				log.Fatalf("Exiting without applying changes.")
+			}
+		}
+
+		report := backup.NewReport()
+		pool := newPool()
+		pool.StopOnError = true
+		failed := pool.Apply(plan, func(item *backup.PlanItem) error {
+			return applyPlanItem(api, item)
+		}, report)
+		if failed != nil {
+			report.PrintSummary(os.Stderr)
+			log.Fatalf("Unable to %s %s: %s", failed.Action, failed.Kind, failed.Error)
+		}
+	},
+}
+
+// validateAppliedNodes checks every node that will be created with the same
+// rules used by node create, and every node that will be updated for problems
+// the update introduces, as node edit does.
+func validateAppliedNodes(plan *backup.Plan, manifests, live *backup.InventoryBackup) {
+	systems := []*types.System{}
+	for _, obj := range mergedObjects(backup.KindSystem, manifests, live) {
+		systems = append(systems, obj.(*types.System))
+	}
+	networks := []*types.Network{}
+	for _, obj := range mergedObjects(backup.KindNetwork, manifests, live) {
+		networks = append(networks, obj.(*types.Network))
+	}
+
+	liveNodes := live.Objects(backup.KindNode)
+	var invalid int
+	for _, item := range plan.Items {
+		node, ok := item.Object.(*types.Node)
+		if !ok {
+			continue
+		}
+
+		var errs []error
+		switch item.Action {
+		case backup.ActionCreate:
+			errs = ingestlib.ValidateNode(node, systems, networks)
+		case backup.ActionUpdate:
+			errs = ingestlib.ValidateEdit(liveNodes[item.ID].(*types.Node), node, systems, networks)
+		}
+		for _, err := range errs {
+			log.Printf("node %s: %v", node.ID(), err)
+			invalid++
+		}
+	}
+	if invalid > 0 {
+		log.Fatalf("refusing to apply, %d problems with nodes", invalid)
+	}
+}
+
+// mergedObjects returns the live objects of a kind, replaced by their
+// manifest versions.
+func mergedObjects(kind backup.Kind, manifests, live *backup.InventoryBackup) map[string]backup.Object {
+	objects := live.Objects(kind)
+	for id, obj := range manifests.Objects(kind) {
+		objects[id] = obj
+	}
+	return objects
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"time"
+
+	"github.com/PolarGeospatialCenter/inventory-cli/pkg/backup"
+	"github.com/spf13/cobra"
+)
+
+var backupOutputFormat string
+
+var cmdBackup = &cobra.Command{
+	Use:   "backup",
+	Short: "work with backup files",
+}
+
+func init() {
+	addBackupReadFlags(cmdBackup.PersistentFlags())
+	addBackupWriteFlags(cmdBackupMigrate.Flags())
+	cmdBackup.AddCommand(cmdBackupMigrate)
+	cmdBackup.AddCommand(cmdBackupVerify)
+	cmdBackupDiff.Flags().StringVarP(&backupOutputFormat, "output", "o", "text", "output format (text or json)")
+	cmdBackup.AddCommand(cmdBackupDiff)
+	cmdBackupDiffLive.Flags().StringVarP(&backupOutputFormat, "output", "o", "text", "output format (text or json)")
+	cmdBackup.AddCommand(cmdBackupDiffLive)
+	rootCmd.AddCommand(cmdBackup)
+}
+
+var cmdBackupMigrate = &cobra.Command{
+	Use:   "migrate filename [output]",
+	Args:  cobra.RangeArgs(1, 2),
+	Short: "rewrite a backup file using the current schema version",
+	Run:   MigrateBackup,
+}
+
+func MigrateBackup(_ *cobra.Command, args []string) {
+	data, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		log.Fatalf("unable to read backup file: %v", err)
+	}
+
+	keys := backupWriteKeys()
+	if backup.IsEncrypted(data) && keys == nil {
+		log.Fatalf("%s is encrypted, use --encrypt or --recipient to encrypt the migrated backup", args[0])
+	}
+
+	backupData, err := backup.Unmarshal(data, backupReadKeys())
+	if err != nil {
+		log.Fatalf("unable to read backup file: %v", err)
+	}
+	logUnverified(args[0], backupData)
+
+	output := args[0]
+	if len(args) == 2 {
+		output = args[1]
+	}
+
+	err = backup.WriteFile(output, backupData, keys)
+	if err != nil {
+		log.Fatalf("unable to write backup file: %v", err)
+	}
+	log.Printf("Migrated %s from schema version %d to %d", args[0], backupData.MigratedFrom, backup.SchemaVersion)
+}
+
+var cmdBackupVerify = &cobra.Command{
+	Use:   "verify filename",
+	Args:  cobra.ExactArgs(1),
+	Short: "check the referential integrity of a backup file without contacting the api",
+	Run:   VerifyBackup,
+}
+
+func VerifyBackup(_ *cobra.Command, args []string) {
+	backupData, err := backup.ReadFile(args[0], backupReadKeys())
+	if err != nil {
+		log.Fatalf("unable to read backup file: %v", err)
+	}
+	logUnverified(args[0], backupData)
+
+	violations := backup.Verify(backupData)
+	for _, violation := range violations {
+		fmt.Println(violation)
+	}
+
+	if len(violations) > 0 {
+		log.Fatalf("found %d problems in %s", len(violations), args[0])
+	}
+	log.Printf("no problems found in %s", args[0])
+}
+
+var cmdBackupDiff = &cobra.Command{
+	Use:   "diff old-filename new-filename",
+	Args:  cobra.ExactArgs(2),
+	Short: "show the objects added, removed and modified between two backup files",
+	Run:   DiffBackups,
+}
+
+func DiffBackups(_ *cobra.Command, args []string) {
+	oldBackup, err := backup.ReadFile(args[0], backupReadKeys())
+	if err != nil {
+		log.Fatalf("unable to read backup file %s: %v", args[0], err)
+	}
+
+	newBackup, err := backup.ReadFile(args[1], backupReadKeys())
+	if err != nil {
+		log.Fatalf("unable to read backup file %s: %v", args[1], err)
+	}
+
+	changeset, err := backup.Compare(oldBackup, newBackup)
+	if err != nil {
+		log.Fatalf("unable to compare backups: %v", err)
+	}
+	printChangeset(changeset, backupOutputFormat)
+}
+
+var cmdBackupDiffLive = &cobra.Command{
+	Use:   "diff-live filename",
+	Args:  cobra.ExactArgs(1),
+	Short: "show the changes made to the live inventory since a backup was taken",
+	Run:   DiffBackupLive,
+}
+
+func DiffBackupLive(_ *cobra.Command, args []string) {
+	backupData, err := backup.ReadFile(args[0], backupReadKeys())
+	if err != nil {
+		log.Fatalf("unable to read backup file %s: %v", args[0], err)
+	}
+
+	api, err := apiConnect()
+	if err != nil {
+		log.Fatalf("unable to connect to api: %v", err)
+	}
+
+	live, err := fetchInventory(api)
+	if err != nil {
+		log.Fatalf("unable to read current inventory: %v", err)
+	}
+	err = fetchReservations(api, live, backupData.IPReservations)
+	if err != nil {
+		log.Fatalf("unable to read current ip reservations: %v", err)
+	}
+
+	changeset, err := backup.Compare(backupData, live)
+	if err != nil {
+		log.Fatalf("unable to compare backup to inventory: %v", err)
+	}
+
+	changed := changeset.MarkChangedAfter(backupData.BackupDate, live)
+	printChangeset(changeset, backupOutputFormat)
+	if backupOutputFormat == "text" {
+		fmt.Printf("%d objects were changed after the backup was taken at %s.\n", changed, backupData.BackupDate.Format(time.RFC3339))
+	}
+}
+
+func printChangeset(changeset *backup.Changeset, format string) {
+	switch format {
+	case "json":
+		txt, err := json.MarshalIndent(changeset, "", "  ")
+		if err != nil {
+			log.Fatalf("unable to marshal changes: %v", err)
+		}
+		fmt.Printf("%s\n", string(txt))
+	case "text":
+		changeset.Print(os.Stdout)
+	default:
+		log.Fatalf("unknown output format: %s", format)
+	}
+}
@@ -1,9 +1,223 @@
 package cmd
 
 import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/PolarGeospatialCenter/inventory-cli/pkg/backup"
 	"github.com/PolarGeospatialCenter/inventory-client/pkg/api/client"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+var (
+	backupIdentityFile string
+	backupEncrypt      bool
+	backupRecipients   []string
+
+	filterKinds  []string
+	backupFilter backup.Filter
+
+	concurrency int
+	requestRate float64
+
+	exportNoRedact bool
 )
 
 func apiConnect() (*client.InventoryApi, error) {
 	return client.NewInventoryApiDefaultConfig(inventoryCfgProfile)
 }
+
+// isNotFound returns true if an api request failed because the object doesn't
+// exist.  The client only reports the status text of failed requests.
+func isNotFound(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), http.StatusText(http.StatusNotFound)+":")
+}
+
+func addBackupReadFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&backupIdentityFile, "identity", "", "age key file used to decrypt encrypted backups, the passphrase is prompted for if not specified")
+}
+
+func addBackupWriteFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&backupEncrypt, "encrypt", false, "encrypt the backup with a passphrase, or for the recipients if any are specified")
+	flags.StringArrayVar(&backupRecipients, "recipient", []string{}, "age public key to encrypt the backup for, may be repeated")
+}
+
+func addRedactFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&exportNoRedact, "no-redact", false, "don't apply the redaction policy configured for the profile")
+}
+
+func addFilterFlags(flags *pflag.FlagSet) {
+	flags.StringArrayVar(&filterKinds, "kind", []string{}, "only include objects of this kind (network, system, node or ipreservation), may be repeated")
+	flags.StringArrayVar(&backupFilter.Systems, "system", []string{}, "only include this system and its nodes, and the networks they use, may be repeated")
+	flags.StringArrayVar(&backupFilter.Roles, "role", []string{}, "only include nodes with this role and the systems and networks they use, may be repeated")
+	flags.StringArrayVar(&backupFilter.Environments, "environment", []string{}, "only include nodes in this environment and the systems and networks they use, may be repeated")
+	flags.StringArrayVar(&backupFilter.IDs, "id", []string{}, "only include the object with this id, may be repeated")
+}
+
+func addConcurrencyFlags(flags *pflag.FlagSet) {
+	flags.IntVar(&concurrency, "concurrency", 1, "number of api requests to make in parallel")
+	flags.Float64Var(&requestRate, "rate", 0, "maximum number of api requests to start per second, 0 is unlimited")
+}
+
+// newPool returns a worker pool configured by the concurrency flags.
+func newPool() *backup.Pool {
+	if concurrency < 1 {
+		log.Fatalf("invalid --concurrency: must be at least 1")
+	}
+	return backup.NewPool(concurrency, requestRate)
+}
+
+// selectedFilter returns the filter built from the filter flags.
+func selectedFilter() backup.Filter {
+	backupFilter.Kinds = []backup.Kind{}
+	for _, name := range filterKinds {
+		kind, err := backup.ParseKind(name)
+		if err != nil {
+			log.Fatalf("invalid --kind: %v", err)
+		}
+		backupFilter.Kinds = append(backupFilter.Kinds, kind)
+	}
+	return backupFilter
+}
+
+// The backup passphrases are only prompted for once, every backup read or
+// written by a command shares them.
+var (
+	readPassphrase = cachedPassphrase(func() (string, error) {
+		prompt := promptui.Prompt{Label: "Backup passphrase", Mask: '*'}
+		return prompt.Run()
+	})
+	newPassphrase = cachedPassphrase(readNewPassphrase)
+)
+
+// backupReadKeys returns the keys used to decrypt backups.
+func backupReadKeys() *backup.Keys {
+	return &backup.Keys{IdentityFile: backupIdentityFile, Passphrase: readPassphrase}
+}
+
+// cachedPassphrase returns a function that calls read until it succeeds, then
+// returns the same passphrase on every later call.
+func cachedPassphrase(read func() (string, error)) func() (string, error) {
+	var passphrase string
+	var ok bool
+	return func() (string, error) {
+		if ok {
+			return passphrase, nil
+		}
+		value, err := read()
+		if err != nil {
+			return "", err
+		}
+		passphrase, ok = value, true
+		return passphrase, nil
+	}
+}
+
+// logUnverified warns about backup records that had no trailer to check them
+// against, so a truncated backup isn't mistaken for a complete one.
+func logUnverified(name string, b *backup.InventoryBackup) {
+	if b.Unverified > 0 {
+		log.Printf("%d records in %s have no end record, they weren't checked for truncation or corruption", b.Unverified, name)
+	}
+}
+
+// backupWriteKeys returns the keys used to encrypt backups, or nil if backups
+// should be written unencrypted.
+func backupWriteKeys() *backup.Keys {
+	if len(backupRecipients) > 0 {
+		return &backup.Keys{Recipients: backupRecipients}
+	}
+
+	if backupEncrypt {
+		return &backup.Keys{Passphrase: newPassphrase}
+	}
+	return nil
+}
+
+func readNewPassphrase() (string, error) {
+	prompt := promptui.Prompt{Label: "New backup passphrase", Mask: '*', Validate: func(value string) error {
+		if value == "" {
+			return fmt.Errorf("Passphrase cannot be empty")
+		}
+		return nil
+	}}
+	passphrase, err := prompt.Run()
+	if err != nil {
+		return "", err
+	}
+
+	prompt = promptui.Prompt{Label: "Confirm backup passphrase", Mask: '*'}
+	confirmation, err := prompt.Run()
+	if err != nil {
+		return "", err
+	}
+
+	if passphrase != confirmation {
+		return "", fmt.Errorf("passphrases don't match")
+	}
+	return passphrase, nil
+}
+
+// profileConfig reads the configuration file of the selected profile, from the
+// same locations as the api client.
+func profileConfig() (*viper.Viper, error) {
+	profile := inventoryCfgProfile
+	if profile == "" {
+		profile = "default"
+	}
+
+	cfg := viper.New()
+	cfg.SetConfigName(profile)
+	cfg.AddConfigPath("/etc/inventory")
+	cfg.AddConfigPath("$HOME/.inventory")
+	err := cfg.ReadInConfig()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read configuration for profile %s: %v", profile, err)
+	}
+	return cfg, nil
+}
+
+// redactionPolicy returns the redaction policy configured under the redact key
+// of the profile, or nil if there isn't one.
+func redactionPolicy() *backup.RedactionPolicy {
+	cfg, err := profileConfig()
+	if err != nil {
+		log.Fatalf("unable to read redaction policy: %v", err)
+	}
+	if !cfg.IsSet("redact") {
+		return nil
+	}
+
+	policy := &backup.RedactionPolicy{}
+	err = cfg.UnmarshalKey("redact", policy)
+	if err != nil {
+		log.Fatalf("unable to parse redaction policy: %v", err)
+	}
+	return policy
+}
+
+// redactBackup applies the redaction policy configured for the profile, unless
+// --no-redact is set.
+func redactBackup(b *backup.InventoryBackup) *backup.InventoryBackup {
+	if exportNoRedact {
+		return b
+	}
+
+	policy := redactionPolicy()
+	if policy == nil {
+		return b
+	}
+
+	redacted, count, err := policy.Apply(b)
+	if err != nil {
+		log.Fatalf("unable to redact backup: %v", err)
+	}
+	if count > 0 {
+		log.Printf("Redacted %d metadata values", count)
+	}
+	return redacted
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/PolarGeospatialCenter/inventory-cli/pkg/backup"
+	"github.com/spf13/cobra"
+)
+
+var cmdHistory = &cobra.Command{
+	Use:   "history",
+	Short: "show how objects changed across the snapshots in a snapshot directory",
+}
+
+func init() {
+	cmdHistory.PersistentFlags().StringVar(&snapshotDir, "dir", "", "directory the snapshots are stored in")
+	cmdHistory.MarkPersistentFlagRequired("dir")
+	cmdHistory.PersistentFlags().StringVarP(&backupOutputFormat, "output", "o", "text", "output format (text or json)")
+	addBackupReadFlags(cmdHistory.PersistentFlags())
+	cmdHistory.AddCommand(cmdHistoryNode)
+	rootCmd.AddCommand(cmdHistory)
+}
+
+var cmdHistoryNode = &cobra.Command{
+	Use:        "node id",
+	ArgAliases: []string{"id"},
+	Args:       cobra.ExactArgs(1),
+	Short:      "show each distinct version of a node and the snapshot it first appeared in",
+	Run: func(cmd *cobra.Command, args []string) {
+		history, err := backup.NodeHistory(snapshotDir, args[0], backupReadKeys())
+		if err != nil {
+			log.Fatalf("unable to read node history: %v", err)
+		}
+
+		switch backupOutputFormat {
+		case "json":
+			txt, err := json.MarshalIndent(history, "", "  ")
+			if err != nil {
+				log.Fatalf("unable to marshal history: %v", err)
+			}
+			fmt.Printf("%s\n", string(txt))
+		case "text":
+			history.Print(os.Stdout)
+		default:
+			log.Fatalf("unknown output format: %s", backupOutputFormat)
+		}
+	},
+}
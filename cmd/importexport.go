@@ -2,141 +2,470 @@ package cmd
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"net"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/PolarGeospatialCenter/inventory-cli/pkg/backup"
+	"github.com/PolarGeospatialCenter/inventory-client/pkg/api/client"
 	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
 )
 
+var (
+	importPlanOnly        bool
+	importOutputFormat    string
+	importContinueOnError bool
+	importReportFile      string
+	importOnConflict      string
+	importPrune           bool
+	importNoPrune         []string
+	importYes             bool
+	importMapFile         string
+	exportSince           []string
+)
+
 func init() {
+	cmdImport.Flags().BoolVar(&importPlanOnly, "plan", false, "show the actions an import would take without changing anything")
+	cmdImport.Flags().StringVarP(&importOutputFormat, "output", "o", "text", "plan output format (text or json)")
+	cmdImport.Flags().BoolVar(&importContinueOnError, "continue-on-error", false, "keep importing after an object fails and summarize the results")
+	cmdImport.Flags().StringVar(&importReportFile, "report", "", "write a json report of the result for every object to this file")
+	cmdImport.Flags().StringVar(&importOnConflict, "on-conflict", string(backup.NewestWins), "how to handle objects that differ from the live inventory (newest-wins, backup-wins, live-wins or interactive)")
+	cmdImport.Flags().BoolVar(&importPrune, "prune", false, "delete live objects that are missing from the backup, limited by the filter flags")
+	cmdImport.Flags().StringArrayVar(&importNoPrune, "no-prune", []string{}, "never delete objects of this kind when pruning, may be repeated")
+	cmdImport.Flags().BoolVar(&importYes, "yes", false, "don't ask for confirmation before deleting objects")
+	cmdImport.Flags().StringVar(&importMapFile, "map", "", "yaml or json file mapping old to new ids per kind, the filter flags select the new ids")
+	addBackupReadFlags(cmdImport.Flags())
+	addFilterFlags(cmdImport.Flags())
+	addConcurrencyFlags(cmdImport.Flags())
 	rootCmd.AddCommand(cmdImport)
+	addBackupWriteFlags(cmdExport.Flags())
+	addFilterFlags(cmdExport.Flags())
+	addConcurrencyFlags(cmdExport.Flags())
+	addRedactFlags(cmdExport.Flags())
+	cmdExport.Flags().StringArrayVar(&exportSince, "since", []string{}, "only export objects changed since this RFC3339 time or backup file, repeat to specify a chain of incremental backups")
 	rootCmd.AddCommand(cmdExport)
 }
 
-type InventoryBackup struct {
-	BackupDate time.Time
-	Nodes      []*types.Node
-	Networks   []*types.Network
-	Systems    []*types.System
+// fetchInventory reads the current state of all backed up object types from the
+// api.  The api can only list ip reservations by mac, so only the reservations
+// for the macs of known nodes are included.
+func fetchInventory(api *client.InventoryApi) (*backup.InventoryBackup, error) {
+	inventory := &backup.InventoryBackup{BackupDate: time.Now()}
+
+	nodes, err := api.Node().GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get nodes: %v", err)
+	}
+	inventory.Nodes = nodes
+
+	systems, err := api.System().GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get systems: %v", err)
+	}
+	inventory.Systems = systems
+
+	networks, err := api.Network().GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get networks: %v", err)
+	}
+	inventory.Networks = networks
+
+	macs := []net.HardwareAddr{}
+	for _, node := range nodes {
+		for _, iface := range node.Networks {
+			if iface == nil {
+				continue
+			}
+			macs = append(macs, iface.NICs...)
+		}
+	}
+
+	pool := newPool()
+	pool.StopOnError = true
+	reservations := make([]types.IPReservationList, len(macs))
+	errs, _ := pool.Run(len(macs), func(i int) error {
+		var err error
+		reservations[i], err = api.IPAM().GetIPReservationsByMAC(macs[i])
+		return err
+	})
+	all := types.IPReservationList{}
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("unable to get ip reservations for %s: %v", macs[i], err)
+		}
+		all = append(all, reservations[i]...)
+	}
+	inventory.AddReservations(all...)
+
+	return inventory, nil
+}
+
+// reservationCoverageNote explains which ip reservations are backed up.
+const reservationCoverageNote = `Only the ip reservations for the macs of nodes in the inventory are included,
+the api can't list other reservations.  Static reservations for other macs,
+such as those made with ip make-static, are not backed up.`
+
+// logReservationCoverage reports the ip reservations included in a backup.
+func logReservationCoverage(b *backup.InventoryBackup) {
+	log.Printf("Included %d ip reservations for the macs of %d nodes, reservations for other macs are not backed up", len(b.IPReservations), len(b.Nodes))
+}
+
+// fetchReservations adds the live reservations for the specified ips to the
+// inventory, so that collisions with reservations that don't belong to a known
+// node are detected.  Ips without a reservation are skipped, any other error
+// is returned rather than hiding a collision.
+func fetchReservations(api *client.InventoryApi, inventory *backup.InventoryBackup, reservations types.IPReservationList) error {
+	found := make([]*types.IPReservation, len(reservations))
+	pool := newPool()
+	pool.StopOnError = true
+	errs, _ := pool.Run(len(reservations), func(i int) error {
+		if reservations[i].IP == nil {
+			return nil
+		}
+		reservation, err := api.IPAM().GetIPReservation(reservations[i].IP.IP)
+		if isNotFound(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		found[i] = reservation
+		return nil
+	})
+
+	all := types.IPReservationList{}
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("unable to get ip reservation for %s: %v", reservations[i].IP.IP, err)
+		}
+		if found[i] != nil {
+			all = append(all, found[i])
+		}
+	}
+	inventory.AddReservations(all...)
+	return nil
+}
+
+func printPlan(plan *backup.Plan, format string) {
+	switch format {
+	case "json":
+		txt, err := json.MarshalIndent(plan, "", "  ")
+		if err != nil {
+			log.Fatalf("unable to marshal plan: %v", err)
+		}
+		fmt.Printf("%s\n", string(txt))
+	case "text":
+		plan.Print(os.Stdout)
+	default:
+		log.Fatalf("unknown output format: %s", format)
+	}
+}
+
+func writeReport(report *backup.Report, filename string) {
+	if filename == "" {
+		return
+	}
+
+	txt, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.Fatalf("unable to marshal import report: %v", err)
+	}
+
+	err = ioutil.WriteFile(filename, txt, 0600)
+	if err != nil {
+		log.Fatalf("unable to write import report: %v", err)
+	}
+}
+
+// refuseBlocked exits if any plan items are blocked by unresolved references
+// or redacted values.
+func refuseBlocked(plan *backup.Plan, operation string) {
+	counts := plan.Counts()
+	if counts[backup.ActionBlocked] == 0 && counts[backup.ActionRedacted] == 0 {
+		return
+	}
+
+	for _, item := range plan.Items {
+		if item.Action == backup.ActionBlocked || item.Action == backup.ActionRedacted {
+			log.Printf("%s %s: %s", item.Kind, item.ID, item.Reason)
+		}
+	}
+	if blocked := counts[backup.ActionBlocked]; blocked > 0 {
+		log.Printf("%d objects have unresolved references", blocked)
+	}
+	if redacted := counts[backup.ActionRedacted]; redacted > 0 {
+		log.Printf("%d objects have redacted values that don't exist in the live inventory", redacted)
+	}
+	log.Fatalf("refusing to %s", operation)
+}
+
+// confirmOverwrite shows the differences between the live and backup versions
+// of an object and asks whether the backup version should be written.
+func confirmOverwrite(item *backup.PlanItem) bool {
+	fmt.Printf("---------\n")
+	fmt.Printf("%s %s: %s\n", item.Kind, item.ID, item.Reason)
+	for _, change := range item.Changes {
+		fmt.Printf("    %s\n", change)
+	}
+	prompt := promptui.Prompt{Label: fmt.Sprintf("Overwrite %s %s with the backup version?", item.Kind, item.ID), IsConfirm: true}
+	_, err := prompt.Run()
+	return err == nil
+}
+
+// prunedKinds returns the kinds that are deleted from the live inventory when
+// they are missing from the backup.
+func prunedKinds() []backup.Kind {
+	if !importPrune {
+		return nil
+	}
+
+	kinds := []backup.Kind{}
+	skipped := make(map[backup.Kind]bool)
+	for _, name := range importNoPrune {
+		kind, err := backup.ParseKind(name)
+		if err != nil {
+			log.Fatalf("invalid --no-prune: %v", err)
+		}
+		skipped[kind] = true
+	}
+	for _, kind := range backup.Kinds {
+		if !skipped[kind] {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+// confirmDeletes shows the plan and asks whether the planned deletions should
+// go ahead.
+func confirmDeletes(plan *backup.Plan) bool {
+	plan.Print(os.Stdout)
+	prompt := promptui.Prompt{Label: fmt.Sprintf("Delete %d objects from the inventory?", plan.Counts()[backup.ActionDelete]), IsConfirm: true}
+	_, err := prompt.Run()
+	return err == nil
+}
+
+// deletePlanItem deletes a single live object from the api.
+func deletePlanItem(api *client.InventoryApi, item *backup.PlanItem) error {
+	switch obj := item.Object.(type) {
+	case *types.Node:
+		return api.Node().Delete(obj)
+	case *types.System:
+		return api.System().Delete(obj)
+	case *types.Network:
+		return api.Network().Delete(obj)
+	case backup.Reservation:
+		return api.IPAM().DeleteIPReservation(obj.IPReservation)
+	}
+	return fmt.Errorf("unsupported object type %T", item.Object)
+}
+
+// applyPlanItem writes a single planned object to the api.
+func applyPlanItem(api *client.InventoryApi, item *backup.PlanItem) error {
+	if item.Action == backup.ActionDelete {
+		return deletePlanItem(api, item)
+	}
+
+	create := item.Action == backup.ActionCreate
+	switch obj := item.Object.(type) {
+	case *types.Node:
+		if create {
+			return api.Node().Create(obj)
+		}
+		return api.Node().Update(obj)
+	case *types.System:
+		if create {
+			return api.System().Create(obj)
+		}
+		return api.System().Update(obj)
+	case *types.Network:
+		if create {
+			return api.Network().Create(obj)
+		}
+		return api.Network().Update(obj)
+	case backup.Reservation:
+		if create {
+			request := &types.IpamIpRequest{Metadata: obj.Metadata}
+			if obj.MAC != nil {
+				request.HwAddress = obj.MAC.String()
+			}
+			if obj.End != nil {
+				request.TTL = time.Until(*obj.End).String()
+			}
+			_, err := api.IPAM().CreateIPReservation(request, obj.IP.IP)
+			if err != nil || obj.Start == nil {
+				return err
+			}
+			// the api sets the start of new reservations to now, restore the original start and end
+		}
+		_, err := api.IPAM().UpdateIPReservation(obj.IPReservation)
+		return err
+	}
+	return fmt.Errorf("unsupported object type %T", item.Object)
 }
 
 var cmdImport = &cobra.Command{
-	Use:        "import filename",
+	Use:        "import filename [incremental-filename...]",
 	ArgAliases: []string{"filename"},
 	Args:       cobra.MinimumNArgs(1),
-	Short:      "import objects from a backup",
+	Short:      "import objects from a backup, followed by any incremental backups based on it",
 	Run: func(cmd *cobra.Command, args []string) {
 		api, err := apiConnect()
 		if err != nil {
 			log.Fatalf("unable to connect to api: %v", err)
 		}
-		backupData := &InventoryBackup{}
 
-		backupDataBytes, err := ioutil.ReadFile(args[0])
+		backupData, err := backup.ReadChain(args, backupReadKeys())
 		if err != nil {
-			log.Fatalf("unable to open backup file: %v", err)
+			log.Fatalf("unable to read backup file: %v", err)
 		}
+		logUnverified(strings.Join(args, ", "), backupData)
+		if importMapFile != "" {
+			idMap, err := backup.ReadIDMap(importMapFile)
+			if err != nil {
+				log.Fatalf("unable to read id map: %v", err)
+			}
+			backupData, err = idMap.Apply(backupData)
+			if err != nil {
+				log.Fatalf("unable to remap ids: %v", err)
+			}
+		}
+
+		filter := selectedFilter()
+		backupData = filter.Apply(backupData)
 
-		err = json.Unmarshal(backupDataBytes, backupData)
+		live, err := fetchInventory(api)
 		if err != nil {
-			log.Fatalf("unable to unmarshal backup data: %v", err)
+			log.Fatalf("unable to read current inventory: %v", err)
+		}
+		err = fetchReservations(api, live, backupData.IPReservations)
+		if err != nil {
+			log.Fatalf("unable to read current ip reservations: %v", err)
 		}
 
-		for _, node := range backupData.Nodes {
-			n, err := api.Node().Get(node.ID())
-			if err != nil {
-				err = api.Node().Create(node)
-				if err != nil {
-					log.Fatalf("Unable to create node: %v", err)
-				}
-			} else if n.Timestamp() < node.Timestamp() {
-				err = api.Node().Update(node)
-				if err != nil {
-					log.Fatalf("Unable to update node: %v", err)
-				}
-			}
+		policy, err := backup.ParseConflictPolicy(importOnConflict)
+		if err != nil {
+			log.Fatalf("invalid --on-conflict: %v", err)
 		}
 
-		for _, system := range backupData.Systems {
-			n, err := api.System().Get(system.ID())
-			if err != nil {
-				err = api.System().Create(system)
-				if err != nil {
-					log.Fatalf("Unable to create system: %v", err)
-				}
-			} else if n.Timestamp() < system.Timestamp() {
-				err = api.System().Update(system)
-				if err != nil {
-					log.Fatalf("Unable to update system: %v", err)
-				}
-			}
+		plan, err := backup.NewPlan(backupData, live, backup.PlanOptions{OnConflict: policy, Prune: prunedKinds(), PruneScope: filter, Redaction: redactionPolicy()})
+		if err != nil {
+			log.Fatalf("unable to plan import: %v", err)
 		}
 
-		for _, network := range backupData.Networks {
-			n, err := api.Network().Get(network.ID())
-			if err != nil {
-				err = api.Network().Create(network)
-				if err != nil {
-					log.Fatalf("Unable to create network: %v", err)
-				}
-			} else if n.Timestamp() < network.Timestamp() {
-				err = api.Network().Update(network)
-				if err != nil {
-					log.Fatalf("Unable to update network: %v", err)
-				}
+		if importPlanOnly {
+			printPlan(plan, importOutputFormat)
+			return
+		}
+
+		if !importContinueOnError {
+			refuseBlocked(plan, "import")
+		}
+
+		if plan.Counts()[backup.ActionDelete] > 0 && !importYes && !confirmDeletes(plan) {
+			log.Fatalf("Exiting without importing.")
+		}
+
+		for _, item := range plan.Items {
+			if item.Action != backup.ActionConflict {
+				continue
+			}
+			if policy == backup.Interactive && confirmOverwrite(item) {
+				item.Action = backup.ActionUpdate
+			} else {
+				log.Printf("Skipping %s %s: %s", item.Kind, item.ID, item.Reason)
 			}
 		}
 
+		report := backup.NewReport()
+		pool := newPool()
+		pool.StopOnError = !importContinueOnError
+		failed := pool.Apply(plan, func(item *backup.PlanItem) error {
+			return applyPlanItem(api, item)
+		}, report)
+		if failed != nil {
+			writeReport(report, importReportFile)
+			log.Fatalf("Unable to %s %s: %s", failed.Action, failed.Kind, failed.Error)
+		}
+		writeReport(report, importReportFile)
+
+		if importContinueOnError {
+			report.PrintSummary(os.Stdout)
+			if report.Failed() > 0 {
+				os.Exit(1)
+			}
+		}
 	},
 }
 
+// incrementalBackup reduces a backup to the changes since the time or chain
+// of backup files specified by --since.
+func incrementalBackup(current *backup.InventoryBackup) *backup.InventoryBackup {
+	if since, err := time.Parse(time.RFC3339, exportSince[0]); err == nil && len(exportSince) == 1 {
+		return backup.Incremental(current, since, nil)
+	}
+
+	base, err := backup.ReadChain(exportSince, backupReadKeys())
+	if err != nil {
+		log.Fatalf("unable to read base backup: %v", err)
+	}
+	base = selectedFilter().Apply(base)
+	return backup.Incremental(current, base.BackupDate, base)
+}
+
 var cmdExport = &cobra.Command{
 	Use:        "export filename",
 	ArgAliases: []string{"filename"},
 	Args:       cobra.MinimumNArgs(1),
 	Short:      "export objects to a backup file",
-	Run: func(cmd *cobra.Command, args []string) {
-		api, err := apiConnect()
-		if err != nil {
-			log.Fatalf("unable to connect to api: %v", err)
-		}
+	Long: `Export objects to a backup file.
 
-		backupData := &InventoryBackup{BackupDate: time.Now()}
+Backups are written as a single json document, or as one json record per line
+if the filename ends in .ndjson or .jsonl.  Record backups are streamed as they
+are written and read, and end with a record holding a checksum so a corrupt
+backup is rejected.  Records grepped or split from a backup can still be
+imported, a warning is logged as they can't be checked.  Add .gz or .zst to the filename to compress the backup.
 
-		nodes, err := api.Node().GetAll()
-		if err != nil {
-			log.Fatalf("unable to get nodes: %v", err)
-		}
-		backupData.Nodes = nodes
+` + reservationCoverageNote + `
 
-		systems, err := api.System().GetAll()
-		if err != nil {
-			log.Fatalf("unable to get systems: %v", err)
-		}
-		backupData.Systems = systems
+Metadata values are redacted according to the redact section of the profile
+configuration, for example:
+
+  redact:
+    keys: ["*password*", "*secret*"]
+    mode: hmac
+    hmac_key: fingerprint-key
 
-		networks, err := api.Network().GetAll()
+The mode is marker or hmac, hmac fingerprints allow changes to redacted values
+to be detected.  Importing a redacted backup keeps the live value of each
+redacted field, objects with redacted fields that have no live value are not
+imported.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		api, err := apiConnect()
 		if err != nil {
-			log.Fatalf("unable to get networks: %v", err)
+			log.Fatalf("unable to connect to api: %v", err)
 		}
-		backupData.Networks = networks
 
-		backupFile, err := os.OpenFile(args[0], os.O_CREATE|os.O_RDWR, 0600)
+		backupData, err := fetchInventory(api)
 		if err != nil {
-			log.Fatalf("unable to open backup file: %v", err)
+			log.Fatalf("unable to read inventory: %v", err)
 		}
+		backupData = selectedFilter().Apply(backupData)
 
-		jsonData, err := json.Marshal(backupData)
-		if err != nil {
-			log.Fatalf("unable to marshal backup data: %v", err)
+		if len(exportSince) > 0 {
+			backupData = incrementalBackup(backupData)
 		}
+		backupData = redactBackup(backupData)
 
-		_, err = backupFile.Write(jsonData)
+		err = backup.WriteFile(args[0], backupData, backupWriteKeys())
 		if err != nil {
 			log.Fatalf("error writing backup to file: %v", err)
 		}
+		logReservationCoverage(backupData)
 	},
 }
@@ -10,9 +10,15 @@ import (
 	"strings"
 	"time"
 
+	"github.com/PolarGeospatialCenter/inventory-cli/pkg/backup"
+	"github.com/PolarGeospatialCenter/inventory-cli/pkg/editor"
 	"github.com/PolarGeospatialCenter/inventory-cli/pkg/ingestlib"
+	"github.com/PolarGeospatialCenter/inventory-cli/pkg/manifest"
+	"github.com/PolarGeospatialCenter/inventory-cli/pkg/printer"
+	"github.com/PolarGeospatialCenter/inventory-cli/pkg/selector"
 	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
 	"github.com/manifoldco/promptui"
+	"github.com/mattn/go-isatty"
 	"github.com/spf13/cobra"
 )
 
@@ -28,12 +34,22 @@ var cmdNode = &cobra.Command{
 
 var systemName, roleName string
 
+var (
+	nodeListOutput   string
+	nodeListColumns  []string
+	nodeListSelector string
+)
+
 func init() {
 	cmdNodeList.Flags().StringVarP(&systemName, "system", "s", "", "list only nodes from system")
 	cmdNodeList.Flags().StringVarP(&roleName, "role", "", "", "list only nodes from role")
+	cmdNodeList.Flags().StringVarP(&nodeListOutput, "output", "o", "list", "output format ("+strings.Join(printer.Formats, ", ")+")")
+	cmdNodeList.Flags().StringVarP(&nodeListSelector, "selector", "l", "", "list only nodes matching this selector, e.g. 'environment=prod,rack in (aa01,aa02),metadata.serial_console exists'")
+	cmdNodeList.Flags().StringSliceVar(&nodeListColumns, "columns", []string{}, "comma separated columns for the table, wide and csv formats ("+strings.Join(printer.ColumnNames(), ", ")+")")
 	cmdNode.AddCommand(cmdNodeList)
-	cmdNode.AddCommand(cmdNodeInteractiveCreate)
+	cmdNode.AddCommand(cmdNodeCreate)
 	cmdNode.AddCommand(cmdNodeInteractiveUpdate)
+	cmdNode.AddCommand(cmdNodeEdit)
 	cmdNode.AddCommand(cmdNodeResetNetworks)
 	cmdNode.AddCommand(cmdNodeDetectNetworks)
 	cmdNode.AddCommand(cmdNodeShow)
@@ -45,10 +61,40 @@ func init() {
 var cmdNodeList = &cobra.Command{
 	Use:   "list",
 	Short: "list all nodes",
-	Run:   ListNodes,
+	Long: `List all nodes.
+
+Nodes are listed as id - hostname lines by default, the same as earlier
+releases, use --output to choose another format.  --columns without --output
+prints a table.
+
+A selector is a comma separated list of requirements that must all match, using
+the keys ` + strings.Join(selector.Keys(), ", ") + `.
+
+  key=value, key!=value      equal, or not equal to a value or glob pattern
+  key in (a,b), key notin    one of, or none of the values
+  key exists, key !exists    the key is set, or not set
+  key~=regexp                matches a regular expression
+  key<value, <=, >, >=       compares u and lastupdated
+  key=low..high              u or lastupdated within a range
+
+Timestamps are RFC3339 times, dates or durations relative to now such as -24h.`,
+	Run: ListNodes,
 }
 
 func ListNodes(cmd *cobra.Command, args []string) {
+	if len(nodeListColumns) > 0 && !cmd.Flags().Changed("output") {
+		nodeListOutput = "table"
+	}
+	nodePrinter, err := printer.NewNodePrinter(nodeListOutput, nodeListColumns)
+	if err != nil {
+		log.Fatalf("invalid output format: %v", err)
+	}
+
+	nodeSelector, err := selector.Parse(nodeListSelector)
+	if err != nil {
+		log.Fatalf("invalid selector: %v", err)
+	}
+
 	apiClient, err := apiConnect()
 	if err != nil {
 		log.Fatalf("Unable to connect to api: %v", err)
@@ -59,6 +105,7 @@ func ListNodes(cmd *cobra.Command, args []string) {
 		log.Fatalf("unable to get nodes: %v", err)
 	}
 
+	selected := make([]*types.Node, 0, len(nodes))
 	for _, node := range nodes {
 		if systemName != "" && node.System != systemName {
 			continue
@@ -67,17 +114,113 @@ func ListNodes(cmd *cobra.Command, args []string) {
 		if roleName != "" && node.Role != roleName {
 			continue
 		}
-		fmt.Printf("%25s - %s\n", node.ID(), node.Hostname())
+
+		if !nodeSelector.Matches(node) {
+			continue
+		}
+		selected = append(selected, node)
+	}
+
+	err = nodePrinter.PrintNodes(os.Stdout, selected)
+	if err != nil {
+		log.Fatalf("unable to print nodes: %v", err)
 	}
 }
 
-var cmdNodeInteractiveCreate = &cobra.Command{
+var cmdNodeCreate = &cobra.Command{
 	Use:   "create",
-	Short: "Create a node interactively",
-	Run:   NodeInteractiveCreate,
+	Short: "Create a node",
+	Long: `Create a node from flags, a YAML or JSON node manifest, or both.  Flags
+override values read from the manifest.
+
+When attached to a terminal, any missing fields are prompted for and the node is
+confirmed before it is created.  Otherwise missing fields are an error.`,
+	Run: NodeCreate,
+}
+
+var (
+	nodeCreateFile     string
+	nodeCreateMetadata []string
+	nodeCreateYes      bool
+	nodeCreateFields   = &types.Node{ChassisLocation: &types.ChassisLocation{}}
+)
+
+func init() {
+	flags := cmdNodeCreate.Flags()
+	flags.StringVarP(&nodeCreateFile, "filename", "f", "", "read the node from a YAML or JSON manifest")
+	flags.StringVar(&nodeCreateFields.InventoryID, "inventory-id", "", "inventory id of the node")
+	flags.StringVar(&nodeCreateFields.Building, "building", "", "building the node is in")
+	flags.StringVar(&nodeCreateFields.Room, "room", "", "room the node is in")
+	flags.StringVar(&nodeCreateFields.Rack, "rack", "", "rack the node is in")
+	flags.UintVar(&nodeCreateFields.BottomU, "bottom-u", 0, "bottom rack space of the node's chassis")
+	flags.StringVar(&nodeCreateFields.ChassisSubIndex, "chassis-sub-index", "", "index of the node within its chassis")
+	flags.StringVar(&nodeCreateFields.System, "system", "", "system the node belongs to")
+	flags.StringVar(&nodeCreateFields.Role, "role", "", "role of the node within its system")
+	flags.StringVar(&nodeCreateFields.Environment, "environment", "", "environment of the node within its system")
+	flags.StringArrayVar(&nodeCreateMetadata, "metadata", []string{}, "metadata key=value, may be repeated")
+	flags.BoolVar(&nodeCreateYes, "yes", false, "don't ask for confirmation before creating the node")
 }
 
-func NodeInteractiveCreate(_ *cobra.Command, _ []string) {
+// nodeFromCreateFlags builds the node to create from the manifest, then the
+// flags that were set.
+func nodeFromCreateFlags(cmd *cobra.Command) (*types.Node, error) {
+	node := &types.Node{}
+	if nodeCreateFile != "" {
+		data, err := ioutil.ReadFile(nodeCreateFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %v", nodeCreateFile, err)
+		}
+		node, err = manifest.DecodeNode(data, nodeCreateFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if node.ChassisLocation == nil {
+		node.ChassisLocation = &types.ChassisLocation{}
+	}
+
+	flags := cmd.Flags()
+	set := func(flag string, dst *string, value string) {
+		if flags.Changed(flag) {
+			*dst = value
+		}
+	}
+	set("inventory-id", &node.InventoryID, nodeCreateFields.InventoryID)
+	set("building", &node.Building, nodeCreateFields.Building)
+	set("room", &node.Room, nodeCreateFields.Room)
+	set("rack", &node.Rack, nodeCreateFields.Rack)
+	set("chassis-sub-index", &node.ChassisSubIndex, nodeCreateFields.ChassisSubIndex)
+	set("system", &node.System, nodeCreateFields.System)
+	set("role", &node.Role, nodeCreateFields.Role)
+	set("environment", &node.Environment, nodeCreateFields.Environment)
+	if flags.Changed("bottom-u") {
+		node.BottomU = nodeCreateFields.BottomU
+	}
+
+	for _, pair := range nodeCreateMetadata {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid metadata '%s', must be key=value", pair)
+		}
+		if node.Metadata == nil {
+			node.Metadata = types.Metadata{}
+		}
+		node.Metadata[parts[0]] = parts[1]
+	}
+	return node, nil
+}
+
+func NodeCreate(cmd *cobra.Command, _ []string) {
+	node, err := nodeFromCreateFlags(cmd)
+	if err != nil {
+		log.Fatalf("Unable to read node: %v", err)
+	}
+
+	interactive := isatty.IsTerminal(os.Stdin.Fd())
+	if missing := ingestlib.MissingFields(node); len(missing) > 0 && !interactive {
+		log.Fatalf("Missing required fields: %s", strings.Join(missing, ", "))
+	}
+
 	apiClient, err := apiConnect()
 	if err != nil {
 		log.Fatalf("unable to connect to api: %v", err)
@@ -90,30 +233,41 @@ func NodeInteractiveCreate(_ *cobra.Command, _ []string) {
 
 	networks, err := apiClient.Network().GetAll()
 	if err != nil {
-		log.Fatalf("unable to get systems: %v", err)
+		log.Fatalf("unable to get networks: %v", err)
 	}
 
-	node := &types.Node{}
-	p := &ingestlib.NodePopulator{Node: node, Systems: systems, Networks: networks}
-	err = p.PopulateNode()
-	if err != nil {
-		log.Fatalf("Unable to populate node data: %v", err)
+	if interactive {
+		p := &ingestlib.NodePopulator{Node: node, Systems: systems, Networks: networks}
+		err = p.PopulateMissing()
+		if err != nil {
+			log.Fatalf("Unable to populate node data: %v", err)
+		}
 	}
+	node.SetTimestamp(time.Now())
 
-	txt, err := json.MarshalIndent(p.Node, "", "  ")
-	if err != nil {
-		log.Fatalf("Unable to marshal node: %v", err)
+	if errs := ingestlib.ValidateNode(node, systems, networks); len(errs) > 0 {
+		for _, err := range errs {
+			log.Printf("%v", err)
+		}
+		log.Fatalf("Invalid node %s", node.ID())
 	}
 
-	fmt.Printf("---------\n")
-	fmt.Printf("%s\n", string(txt))
-	prompt := promptui.Prompt{Label: "Create this node?", IsConfirm: true}
-	_, err = prompt.Run()
-	if err != nil {
-		log.Fatalf("Exiting without creating node.")
+	if interactive && !nodeCreateYes {
+		txt, err := json.MarshalIndent(node, "", "  ")
+		if err != nil {
+			log.Fatalf("Unable to marshal node: %v", err)
+		}
+
+		fmt.Printf("---------\n")
+		fmt.Printf("%s\n", string(txt))
+		prompt := promptui.Prompt{Label: "Create this node?", IsConfirm: true}
+		_, err = prompt.Run()
+		if err != nil {
+			log.Fatalf("Exiting without creating node.")
+		}
 	}
 
-	err = apiClient.Node().Create(p.Node)
+	err = apiClient.Node().Create(node)
 	if err != nil {
 		log.Fatalf("unable to create node: %v", err)
 	}
@@ -173,6 +327,103 @@ func NodeInteractiveUpdate(_ *cobra.Command, args []string) {
 	}
 }
 
+var cmdNodeEdit = &cobra.Command{
+	Use:        "edit nodeId",
+	ArgAliases: []string{"nodeId"},
+	Args:       cobra.ExactArgs(1),
+	Short:      "Edit a node as YAML in $EDITOR",
+	Long: `Edit a node as YAML in the editor set by $VISUAL or $EDITOR, vi by default.
+
+If the edit introduces a problem, such as an unknown role or an invalid rack,
+the editor is reopened with the errors as comments at the top of the file.
+Fields the node was already missing don't have to be filled in.  Save an empty
+file to abandon the edit.`,
+	Run: NodeEdit,
+}
+
+const nodeEditHeader = `# Edit the node below, lines starting with # are ignored.  Save an empty file
+# to abandon the edit.
+#
+`
+
+func NodeEdit(_ *cobra.Command, args []string) {
+	apiClient, err := apiConnect()
+	if err != nil {
+		log.Fatalf("unable to connect to api: %v", err)
+	}
+
+	node, err := apiClient.Node().Get(args[0])
+	if err != nil {
+		log.Fatalf("Unable to get node %s: %v", args[0], err)
+	}
+
+	systems, err := apiClient.System().GetAll()
+	if err != nil {
+		log.Fatalf("unable to get systems: %v", err)
+	}
+
+	networks, err := apiClient.Network().GetAll()
+	if err != nil {
+		log.Fatalf("unable to get networks: %v", err)
+	}
+
+	original, err := manifest.Encode(node)
+	if err != nil {
+		log.Fatalf("Unable to encode node: %v", err)
+	}
+
+	text := append([]byte(nodeEditHeader), original...)
+	var edited *types.Node
+	for edited == nil {
+		text, err = editor.Edit(text, ".yaml")
+		if err != nil {
+			log.Fatalf("Unable to edit node: %v", err)
+		}
+		if editor.IsEmpty(text) {
+			log.Fatalf("Edit cancelled, no changes made.")
+		}
+
+		edited, err = manifest.DecodeNode(text, node.ID())
+		errs := []error{err}
+		if err == nil {
+			errs = ingestlib.ValidateEdit(node, edited, systems, networks)
+			if edited.InventoryID != node.InventoryID {
+				errs = append(errs, fmt.Errorf("inventory id can't be changed from %s", node.InventoryID))
+			}
+		}
+		if len(errs) > 0 {
+			edited = nil
+			text = editor.WithErrors(text, errs)
+		}
+	}
+
+	edited.LastUpdated = node.LastUpdated
+	changes, err := backup.Diff(node, edited)
+	if err != nil {
+		log.Fatalf("Unable to compare node: %v", err)
+	}
+	if len(changes) == 0 {
+		log.Printf("Node %s is unchanged.", node.ID())
+		return
+	}
+
+	fmt.Printf("node %s:\n", node.ID())
+	for _, change := range changes {
+		fmt.Printf("    %s\n", change)
+	}
+	prompt := promptui.Prompt{Label: "Update this node?", IsConfirm: true}
+	_, err = prompt.Run()
+	if err != nil {
+		log.Fatalf("Exiting without updating node.")
+	}
+
+	edited.SetTimestamp(time.Now())
+	err = apiClient.Node().Update(edited)
+	if err != nil {
+		log.Fatalf("unable to update node: %v", err)
+	}
+}
+
 var cmdNodeShow = &cobra.Command{
 	Use:   "show",
 	Short: "show node",
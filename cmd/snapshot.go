@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PolarGeospatialCenter/inventory-cli/pkg/backup"
+	"github.com/spf13/cobra"
+)
+
+var (
+	snapshotDir         string
+	snapshotCompression string
+	snapshotRetention   backup.Retention
+)
+
+func init() {
+	cmdBackupSnapshot.Flags().StringVar(&snapshotDir, "dir", "", "directory the snapshots are stored in")
+	cmdBackupSnapshot.MarkFlagRequired("dir")
+	cmdBackupSnapshot.Flags().StringVar(&snapshotCompression, "compression", "none", "compression used for the snapshot (none, gzip or zstd)")
+	cmdBackupSnapshot.Flags().IntVar(&snapshotRetention.Hourly, "keep-hourly", 0, "number of hours to keep the newest snapshot for")
+	cmdBackupSnapshot.Flags().IntVar(&snapshotRetention.Daily, "keep-daily", 0, "number of days to keep the newest snapshot for")
+	cmdBackupSnapshot.Flags().IntVar(&snapshotRetention.Weekly, "keep-weekly", 0, "number of weeks to keep the newest snapshot for")
+	cmdBackupSnapshot.Flags().IntVar(&snapshotRetention.Monthly, "keep-monthly", 0, "number of months to keep the newest snapshot for")
+	addBackupWriteFlags(cmdBackupSnapshot.Flags())
+	addRedactFlags(cmdBackupSnapshot.Flags())
+	cmdBackup.AddCommand(cmdBackupSnapshot)
+
+	cmdBackupList.Flags().StringVar(&snapshotDir, "dir", "", "directory the snapshots are stored in")
+	cmdBackupList.MarkFlagRequired("dir")
+	cmdBackupList.Flags().StringVarP(&backupOutputFormat, "output", "o", "text", "output format (text or json)")
+	cmdBackup.AddCommand(cmdBackupList)
+}
+
+var cmdBackupSnapshot = &cobra.Command{
+	Use:   "snapshot",
+	Args:  cobra.NoArgs,
+	Short: "write a timestamped backup to a snapshot directory and remove snapshots that are no longer retained",
+	Long: `Write a timestamped backup to a snapshot directory and remove snapshots that are no longer retained.
+
+The newest snapshot of each of the last --keep-hourly hours, --keep-daily days,
+--keep-weekly weeks and --keep-monthly months is kept.  If no retention is
+specified every snapshot is kept.
+
+` + reservationCoverageNote,
+	Run: func(cmd *cobra.Command, args []string) {
+		compression, err := backup.ParseCompression(snapshotCompression)
+		if err != nil {
+			log.Fatalf("invalid --compression: %v", err)
+		}
+
+		err = os.MkdirAll(snapshotDir, 0700)
+		if err != nil {
+			log.Fatalf("unable to create snapshot directory: %v", err)
+		}
+
+		api, err := apiConnect()
+		if err != nil {
+			log.Fatalf("unable to connect to api: %v", err)
+		}
+
+		backupData, err := fetchInventory(api)
+		if err != nil {
+			log.Fatalf("unable to read inventory: %v", err)
+		}
+
+		backupData = redactBackup(backupData)
+
+		snapshot, err := backup.WriteSnapshot(snapshotDir, backupData, compression, backupWriteKeys())
+		if err != nil {
+			log.Fatalf("unable to write snapshot: %v", err)
+		}
+		fmt.Printf("Wrote snapshot %s\n", snapshot.Filename)
+		logReservationCoverage(backupData)
+
+		removed, err := backup.PruneSnapshots(snapshotDir, snapshotRetention)
+		if err != nil {
+			log.Fatalf("unable to prune snapshots: %v", err)
+		}
+		for _, s := range removed {
+			fmt.Printf("Removed snapshot %s\n", s.Filename)
+		}
+	},
+}
+
+var cmdBackupList = &cobra.Command{
+	Use:   "list",
+	Args:  cobra.NoArgs,
+	Short: "list the snapshots in a snapshot directory",
+	Run: func(cmd *cobra.Command, args []string) {
+		snapshots, err := backup.ReadIndex(snapshotDir)
+		if err != nil {
+			log.Fatalf("unable to list snapshots: %v", err)
+		}
+
+		switch backupOutputFormat {
+		case "json":
+			txt, err := json.MarshalIndent(snapshots, "", "  ")
+			if err != nil {
+				log.Fatalf("unable to marshal snapshots: %v", err)
+			}
+			fmt.Printf("%s\n", string(txt))
+		case "text":
+			tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+			fmt.Fprintf(tw, "DATE\tFILENAME")
+			for _, kind := range backup.Kinds {
+				fmt.Fprintf(tw, "\t%sS", strings.ToUpper(string(kind)))
+			}
+			fmt.Fprintf(tw, "\n")
+			for _, s := range snapshots {
+				fmt.Fprintf(tw, "%s\t%s", s.Date.Local().Format(time.RFC3339), s.Filename)
+				for _, kind := range backup.Kinds {
+					fmt.Fprintf(tw, "\t%d", s.Counts[kind])
+				}
+				fmt.Fprintf(tw, "\n")
+			}
+			tw.Flush()
+		default:
+			log.Fatalf("unknown output format: %s", backupOutputFormat)
+		}
+	},
+}
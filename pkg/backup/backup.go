@@ -0,0 +1,73 @@
+package backup
+
+import (
+	"time"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+// Kind identifies the type of an object stored in a backup.
+type Kind string
+
+const (
+	KindNode    Kind = "node"
+	KindSystem  Kind = "system"
+	KindNetwork Kind = "network"
+
+	KindIPReservation Kind = "ipreservation"
+)
+
+// Kinds lists every kind of object stored in a backup.
+var Kinds = []Kind{KindNetwork, KindSystem, KindNode, KindIPReservation}
+
+// Object is implemented by every inventory object that can be backed up.
+type Object interface {
+	ID() string
+	Timestamp() int64
+}
+
+// InventoryBackup is a point in time copy of the objects stored in the inventory api.
+type InventoryBackup struct {
+	SchemaVersion int
+	BackupDate    time.Time
+	Nodes         []*types.Node
+	Networks      []*types.Network
+	Systems       []*types.System
+
+	IPReservations types.IPReservationList
+
+	// Since is set on incremental backups, which only contain the objects
+	// changed after this time and the tombstones of objects deleted since.
+	Since      *time.Time  `json:",omitempty"`
+	Tombstones []ObjectRef `json:",omitempty"`
+
+	// MigratedFrom is the schema version the backup was read from
+	MigratedFrom int `json:"-"`
+	// Unverified is the number of records read without a trailer to check
+	// them against, such as records grepped or split from a larger backup
+	Unverified int `json:"-"`
+}
+
+// Objects returns all objects of the specified kind, keyed by ID.
+func (b *InventoryBackup) Objects(kind Kind) map[string]Object {
+	objects := make(map[string]Object)
+	switch kind {
+	case KindNode:
+		for _, node := range b.Nodes {
+			objects[node.ID()] = node
+		}
+	case KindSystem:
+		for _, system := range b.Systems {
+			objects[system.ID()] = system
+		}
+	case KindNetwork:
+		for _, network := range b.Networks {
+			objects[network.ID()] = network
+		}
+	case KindIPReservation:
+		for _, reservation := range b.IPReservations {
+			objects[Reservation{reservation}.ID()] = Reservation{reservation}
+		}
+	}
+	return objects
+}
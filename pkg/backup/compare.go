@@ -0,0 +1,122 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// ChangeType describes how an object differs between two backups.
+type ChangeType string
+
+const (
+	ChangeAdded    ChangeType = "added"
+	ChangeRemoved  ChangeType = "removed"
+	ChangeModified ChangeType = "modified"
+)
+
+var changeSymbols = map[ChangeType]string{
+	ChangeAdded:    "+",
+	ChangeRemoved:  "-",
+	ChangeModified: "~",
+}
+
+// ObjectChange is an object that was added, removed or modified between two backups.
+type ObjectChange struct {
+	Kind    Kind          `json:"kind"`
+	ID      string        `json:"id"`
+	Change  ChangeType    `json:"change"`
+	Changes []FieldChange `json:"changes,omitempty"`
+
+	// ChangedAfter is set for objects whose timestamp is later than a reference date
+	ChangedAfter bool `json:"changedAfter,omitempty"`
+}
+
+// Changeset lists the differences between two backups.
+type Changeset struct {
+	Changes []*ObjectChange `json:"changes"`
+}
+
+// Compare matches the objects in two backups by ID and returns the objects
+// that were added, removed or modified in new.
+func Compare(old, new *InventoryBackup) (*Changeset, error) {
+	changeset := &Changeset{Changes: []*ObjectChange{}}
+	for _, kind := range Kinds {
+		oldObjects := old.Objects(kind)
+		newObjects := new.Objects(kind)
+
+		for id, obj := range newObjects {
+			oldObj, ok := oldObjects[id]
+			if !ok {
+				changeset.Changes = append(changeset.Changes, &ObjectChange{Kind: kind, ID: id, Change: ChangeAdded})
+				continue
+			}
+
+			changes, err := Diff(oldObj, obj)
+			if err != nil {
+				return nil, fmt.Errorf("unable to compare %s %s: %v", kind, id, err)
+			}
+			if len(changes) > 0 {
+				changeset.Changes = append(changeset.Changes, &ObjectChange{Kind: kind, ID: id, Change: ChangeModified, Changes: changes})
+			}
+		}
+
+		for id := range oldObjects {
+			if _, ok := newObjects[id]; !ok {
+				changeset.Changes = append(changeset.Changes, &ObjectChange{Kind: kind, ID: id, Change: ChangeRemoved})
+			}
+		}
+	}
+
+	sort.SliceStable(changeset.Changes, func(i, j int) bool {
+		a, b := changeset.Changes[i], changeset.Changes[j]
+		return lessRef(ObjectRef{a.Kind, a.ID}, ObjectRef{b.Kind, b.ID})
+	})
+	return changeset, nil
+}
+
+// MarkChangedAfter flags the changed objects whose timestamp in current is
+// later than t.  It returns the number of objects flagged.
+func (c *Changeset) MarkChangedAfter(t time.Time, current *InventoryBackup) int {
+	var marked int
+	objects := make(map[Kind]map[string]Object)
+	for _, change := range c.Changes {
+		if objects[change.Kind] == nil {
+			objects[change.Kind] = current.Objects(change.Kind)
+		}
+
+		obj, ok := objects[change.Kind][change.ID]
+		if ok && obj.Timestamp() > t.Unix() {
+			change.ChangedAfter = true
+			marked++
+		}
+	}
+	return marked
+}
+
+// Counts returns the number of objects for each type of change.
+func (c *Changeset) Counts() map[ChangeType]int {
+	counts := make(map[ChangeType]int)
+	for _, change := range c.Changes {
+		counts[change.Change]++
+	}
+	return counts
+}
+
+// Print writes a human readable version of the changeset to w.
+func (c *Changeset) Print(w io.Writer) {
+	for _, change := range c.Changes {
+		var note string
+		if change.ChangedAfter {
+			note = "  (changed after reference date)"
+		}
+		fmt.Fprintf(w, "%s %-8s %s%s\n", changeSymbols[change.Change], change.Kind, change.ID, note)
+		for _, fieldChange := range change.Changes {
+			fmt.Fprintf(w, "    %s\n", fieldChange)
+		}
+	}
+
+	counts := c.Counts()
+	fmt.Fprintf(w, "%d added, %d removed, %d modified.\n", counts[ChangeAdded], counts[ChangeRemoved], counts[ChangeModified])
+}
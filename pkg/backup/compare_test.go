@@ -0,0 +1,79 @@
+package backup
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+func TestCompare(t *testing.T) {
+	mac1, _ := net.ParseMAC("00:01:02:03:04:05")
+	mac2, _ := net.ParseMAC("00:01:02:03:04:06")
+	mac3, _ := net.ParseMAC("00:01:02:03:04:07")
+
+	old := &InventoryBackup{
+		Nodes: []*types.Node{
+			{InventoryID: "tst-0001", Networks: types.NICInfoMap{"prod": {NICs: []net.HardwareAddr{mac1, mac2}}}},
+			{InventoryID: "tst-0002"},
+		},
+		Systems: []*types.System{{Name: "tst", Roles: []string{"compute"}}},
+	}
+	new := &InventoryBackup{
+		Nodes: []*types.Node{
+			{InventoryID: "tst-0001", Networks: types.NICInfoMap{"prod": {NICs: []net.HardwareAddr{mac3, mac2}}}},
+			{InventoryID: "tst-0003"},
+		},
+		Systems: []*types.System{{Name: "tst", Roles: []string{"storage", "compute"}}},
+	}
+
+	changeset, err := Compare(old, new)
+	if err != nil {
+		t.Fatalf("unable to compare backups: %v", err)
+	}
+
+	out := &bytes.Buffer{}
+	changeset.Print(out)
+	expected := `~ system   tst
+    Roles: added ["storage"]
+~ node     tst-0001
+    Networks.prod.nics: added ["00:01:02:03:04:07"], removed ["00:01:02:03:04:05"]
+- node     tst-0002
++ node     tst-0003
+1 added, 1 removed, 2 modified.
+`
+	if out.String() != expected {
+		t.Errorf("unexpected changeset, got:\n%s\nexpected:\n%s", out.String(), expected)
+	}
+}
+
+func TestMarkChangedAfter(t *testing.T) {
+	backupDate := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	old := &InventoryBackup{
+		BackupDate: backupDate,
+		Nodes: []*types.Node{
+			{InventoryID: "tst-0001", Role: "compute", LastUpdated: backupDate.Add(-time.Hour)},
+			{InventoryID: "tst-0002", Role: "compute", LastUpdated: backupDate.Add(-time.Hour)},
+		},
+	}
+	live := &InventoryBackup{
+		Nodes: []*types.Node{
+			{InventoryID: "tst-0001", Role: "storage", LastUpdated: backupDate.Add(time.Hour)},
+			{InventoryID: "tst-0002", Role: "storage", LastUpdated: backupDate.Add(-time.Hour)},
+		},
+	}
+
+	changeset, err := Compare(old, live)
+	if err != nil {
+		t.Fatalf("unable to compare backups: %v", err)
+	}
+
+	if marked := changeset.MarkChangedAfter(old.BackupDate, live); marked != 1 {
+		t.Errorf("expected 1 object changed after backup, got %d", marked)
+	}
+	if !changeset.Changes[0].ChangedAfter || changeset.Changes[1].ChangedAfter {
+		t.Errorf("wrong objects marked as changed after backup")
+	}
+}
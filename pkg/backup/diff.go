@@ -0,0 +1,179 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FieldChange describes a single field that differs between two versions of
+// an object.  Changes to lists of simple values, like MAC addresses or roles,
+// are reported as the values added to and removed from the list.
+type FieldChange struct {
+	Field   string        `json:"field"`
+	Old     interface{}   `json:"old,omitempty"`
+	New     interface{}   `json:"new,omitempty"`
+	Added   []interface{} `json:"added,omitempty"`
+	Removed []interface{} `json:"removed,omitempty"`
+}
+
+func (c FieldChange) String() string {
+	if c.Added != nil || c.Removed != nil {
+		changes := []string{}
+		if len(c.Added) > 0 {
+			changes = append(changes, fmt.Sprintf("added %s", formatValue(c.Added)))
+		}
+		if len(c.Removed) > 0 {
+			changes = append(changes, fmt.Sprintf("removed %s", formatValue(c.Removed)))
+		}
+		return fmt.Sprintf("%s: %s", c.Field, strings.Join(changes, ", "))
+	}
+	return fmt.Sprintf("%s: %s -> %s", c.Field, formatValue(c.Old), formatValue(c.New))
+}
+
+func formatValue(v interface{}) string {
+	if v == nil {
+		return "<unset>"
+	}
+	txt, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(txt)
+}
+
+// Diff compares the json representations of two objects and returns the fields
+// that differ, sorted by field name.
+func Diff(old, new interface{}) ([]FieldChange, error) {
+	oldValue, err := ToGeneric(old)
+	if err != nil {
+		return nil, err
+	}
+
+	newValue, err := ToGeneric(new)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := diffValues("", oldValue, newValue)
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+// ToGeneric converts an object to the maps, slices and scalars of its json
+// representation.
+func ToGeneric(obj interface{}) (interface{}, error) {
+	txt, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal object: %v", err)
+	}
+
+	var value interface{}
+	err = json.Unmarshal(txt, &value)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal object: %v", err)
+	}
+	return value, nil
+}
+
+func joinField(prefix, field string) string {
+	if prefix == "" {
+		return field
+	}
+	return prefix + "." + field
+}
+
+func diffValues(field string, old, new interface{}) []FieldChange {
+	oldMap, oldIsMap := old.(map[string]interface{})
+	newMap, newIsMap := new.(map[string]interface{})
+	if oldIsMap && newIsMap {
+		changes := []FieldChange{}
+		for key, oldChild := range oldMap {
+			changes = append(changes, diffValues(joinField(field, key), oldChild, newMap[key])...)
+		}
+		for key, newChild := range newMap {
+			if _, ok := oldMap[key]; !ok {
+				changes = append(changes, diffValues(joinField(field, key), nil, newChild)...)
+			}
+		}
+		return changes
+	}
+
+	if reflect.DeepEqual(old, new) || (isEmpty(old) && isEmpty(new)) {
+		return nil
+	}
+
+	oldList, oldIsList := scalarList(old)
+	newList, newIsList := scalarList(new)
+	if oldIsList && newIsList {
+		added, removed := diffLists(oldList, newList)
+		if len(added) == 0 && len(removed) == 0 {
+			return nil
+		}
+		return []FieldChange{{Field: field, Added: added, Removed: removed}}
+	}
+	return []FieldChange{{Field: field, Old: old, New: new}}
+}
+
+// scalarList returns the value as a list if it is a list that only contains
+// strings, numbers or booleans.  A missing value is treated as an empty list.
+func scalarList(v interface{}) ([]interface{}, bool) {
+	if v == nil {
+		return []interface{}{}, true
+	}
+
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	for _, item := range list {
+		switch item.(type) {
+		case string, float64, bool:
+		default:
+			return nil, false
+		}
+	}
+	return list, true
+}
+
+// diffLists compares two lists as multisets, ignoring the order of their items.
+func diffLists(old, new []interface{}) ([]interface{}, []interface{}) {
+	remaining := make(map[interface{}]int)
+	for _, item := range old {
+		remaining[item]++
+	}
+
+	added := []interface{}{}
+	for _, item := range new {
+		if remaining[item] > 0 {
+			remaining[item]--
+			continue
+		}
+		added = append(added, item)
+	}
+
+	removed := []interface{}{}
+	for _, item := range old {
+		if remaining[item] > 0 {
+			remaining[item]--
+			removed = append(removed, item)
+		}
+	}
+	return added, removed
+}
+
+// isEmpty treats null, empty objects and empty lists as equivalent so that
+// unpopulated metadata doesn't show up as a change.
+func isEmpty(v interface{}) bool {
+	switch value := v.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(value) == 0
+	case []interface{}:
+		return len(value) == 0
+	}
+	return false
+}
@@ -0,0 +1,130 @@
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"filippo.io/age"
+)
+
+var ageHeader = []byte("age-encryption.org/v1\n")
+
+// Keys holds the keys used to encrypt and decrypt backups.  Backups are
+// encrypted in the age format, either for a list of X25519 recipients or with
+// a passphrase.
+type Keys struct {
+	// Recipients are the age public keys a backup is encrypted for
+	Recipients []string
+	// IdentityFile contains the age private keys used to decrypt a backup
+	IdentityFile string
+	// Passphrase is called to read the passphrase for a backup, it is only
+	// called if a passphrase is required.
+	Passphrase func() (string, error)
+}
+
+// IsEncrypted returns true if the data is an encrypted backup.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, ageHeader)
+}
+
+func (k *Keys) encrypts() bool {
+	return k != nil && (len(k.Recipients) > 0 || k.Passphrase != nil)
+}
+
+func (k *Keys) recipients() ([]age.Recipient, error) {
+	if len(k.Recipients) > 0 {
+		recipients := make([]age.Recipient, 0, len(k.Recipients))
+		for _, r := range k.Recipients {
+			recipient, err := age.ParseX25519Recipient(r)
+			if err != nil {
+				return nil, fmt.Errorf("invalid recipient '%s': %v", r, err)
+			}
+			recipients = append(recipients, recipient)
+		}
+		return recipients, nil
+	}
+
+	passphrase, err := k.Passphrase()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read passphrase: %v", err)
+	}
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return []age.Recipient{recipient}, nil
+}
+
+func (k *Keys) identities() ([]age.Identity, error) {
+	if k == nil || (k.IdentityFile == "" && k.Passphrase == nil) {
+		return nil, fmt.Errorf("backup is encrypted, a key file or passphrase is required")
+	}
+
+	if k.IdentityFile != "" {
+		f, err := os.Open(k.IdentityFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open key file: %v", err)
+		}
+		defer f.Close()
+		return age.ParseIdentities(f)
+	}
+
+	passphrase, err := k.Passphrase()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read passphrase: %v", err)
+	}
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return []age.Identity{identity}, nil
+}
+
+// encryptingWriter returns a writer that encrypts data for the keys into w.
+// It must be closed to finish the encrypted stream.
+func encryptingWriter(w io.Writer, keys *Keys) (io.WriteCloser, error) {
+	recipients, err := keys.recipients()
+	if err != nil {
+		return nil, err
+	}
+
+	ew, err := age.Encrypt(w, recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encrypt backup: %v", err)
+	}
+	return ew, nil
+}
+
+func encrypt(data []byte, keys *Keys) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w, err := encryptingWriter(buf, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = w.Write(data)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to encrypt backup: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decryptingReader returns a reader that decrypts the encrypted backup read
+// from r.
+func decryptingReader(r io.Reader, keys *Keys) (io.Reader, error) {
+	identities, err := keys.identities()
+	if err != nil {
+		return nil, err
+	}
+
+	dr, err := age.Decrypt(r, identities...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt backup: %v", err)
+	}
+	return dr, nil
+}
@@ -0,0 +1,66 @@
+package backup
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+func TestEncryptRecipients(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("unable to generate identity: %v", err)
+	}
+
+	keyFile, err := ioutil.TempFile("", "backup-key")
+	if err != nil {
+		t.Fatalf("unable to create key file: %v", err)
+	}
+	defer os.Remove(keyFile.Name())
+	keyFile.WriteString(identity.String() + "\n")
+	keyFile.Close()
+
+	b := &InventoryBackup{Nodes: []*types.Node{{InventoryID: "tst-0001", Metadata: types.Metadata{"bmc_password": "secret"}}}}
+	data, err := Marshal(b, CompressionGzip, &Keys{Recipients: []string{identity.Recipient().String()}})
+	if err != nil {
+		t.Fatalf("unable to marshal encrypted backup: %v", err)
+	}
+
+	if !IsEncrypted(data) {
+		t.Fatalf("expected encrypted backup")
+	}
+
+	if _, err := Unmarshal(data, nil); err == nil {
+		t.Errorf("expected error reading encrypted backup without keys")
+	}
+
+	restored, err := Unmarshal(data, &Keys{IdentityFile: keyFile.Name()})
+	if err != nil {
+		t.Fatalf("unable to decrypt backup: %v", err)
+	}
+	if restored.Nodes[0].Metadata["bmc_password"] != "secret" {
+		t.Errorf("unexpected metadata after decryption: %v", restored.Nodes[0].Metadata)
+	}
+}
+
+func TestEncryptPassphrase(t *testing.T) {
+	passphrase := func(p string) func() (string, error) {
+		return func() (string, error) { return p, nil }
+	}
+
+	data, err := Marshal(&InventoryBackup{}, CompressionNone, &Keys{Passphrase: passphrase("correct horse")})
+	if err != nil {
+		t.Fatalf("unable to marshal encrypted backup: %v", err)
+	}
+
+	if _, err := Unmarshal(data, &Keys{Passphrase: passphrase("battery staple")}); err == nil {
+		t.Errorf("expected error decrypting with the wrong passphrase")
+	}
+
+	if _, err := Unmarshal(data, &Keys{Passphrase: passphrase("correct horse")}); err != nil {
+		t.Errorf("unable to decrypt backup: %v", err)
+	}
+}
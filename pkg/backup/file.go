@@ -0,0 +1,304 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const checksumPrefix = "sha256:"
+
+// envelope wraps an encoded backup with a checksum of its exact bytes.
+type envelope struct {
+	Checksum string
+	Backup   json.RawMessage
+}
+
+// Compression is the algorithm used to compress a backup file.
+type Compression string
+
+const (
+	CompressionNone Compression = ""
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// CompressionForFile chooses the compression for a backup based on the
+// extension of its filename.
+func CompressionForFile(filename string) Compression {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".gz", ".gzip":
+		return CompressionGzip
+	case ".zst", ".zstd":
+		return CompressionZstd
+	}
+	return CompressionNone
+}
+
+// detectCompression identifies compressed data by its magic number.
+func detectCompression(data []byte) Compression {
+	switch {
+	case bytes.HasPrefix(data, gzipMagic):
+		return CompressionGzip
+	case bytes.HasPrefix(data, zstdMagic):
+		return CompressionZstd
+	}
+	return CompressionNone
+}
+
+// decompressingReader returns a reader that decompresses the data read from r
+// if it is compressed, and a function to release the decompressor.
+func decompressingReader(r *bufio.Reader) (io.Reader, func(), error) {
+	magic, _ := r.Peek(len(zstdMagic))
+	switch detectCompression(magic) {
+	case CompressionGzip:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to read gzip data: %v", err)
+		}
+		return gr, func() { gr.Close() }, nil
+	case CompressionZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to read zstd data: %v", err)
+		}
+		return zr, zr.Close, nil
+	}
+	return r, func() {}, nil
+}
+
+func compressingWriter(w io.Writer, compression Compression) (io.WriteCloser, error) {
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w)
+	}
+	return nopWriteCloser{w}, nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// Unmarshal reads a backup from the contents of a file in either format.
+// Encrypted data is decrypted with keys, compressed data is decompressed, the
+// checksum is verified if present and the backup is migrated to the current
+// schema version.
+func Unmarshal(data []byte, keys *Keys) (*InventoryBackup, error) {
+	return Read(bytes.NewReader(data), keys)
+}
+
+// Read reads a backup from a stream like Unmarshal.  NDJSON backups are
+// decoded as they are read, without holding the whole file in memory.
+func Read(r io.Reader, keys *Keys) (*InventoryBackup, error) {
+	br := bufio.NewReader(r)
+	if prefix, _ := br.Peek(len(ageHeader)); IsEncrypted(prefix) {
+		dr, err := decryptingReader(br, keys)
+		if err != nil {
+			return nil, err
+		}
+		br = bufio.NewReader(dr)
+	}
+
+	dr, release, err := decompressingReader(br)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	br = bufio.NewReader(dr)
+
+	if prefix, _ := br.Peek(512); isNDJSON(prefix) {
+		return DecodeNDJSON(br)
+	}
+
+	data, err := ioutil.ReadAll(br)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read backup data: %v", err)
+	}
+
+	wrapped := &envelope{}
+	err = json.Unmarshal(data, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal backup data: %v", err)
+	}
+
+	if wrapped.Backup == nil {
+		// backups written before checksums were added are a bare document
+		return Decode(data)
+	}
+
+	sum := sha256.Sum256(wrapped.Backup)
+	if wrapped.Checksum != checksumPrefix+hex.EncodeToString(sum[:]) {
+		return nil, fmt.Errorf("checksum mismatch, backup data is corrupt")
+	}
+	return Decode(wrapped.Backup)
+}
+
+// Marshal encodes a backup with a checksum of its contents, compressing it if
+// requested.  The backup is encrypted if keys includes recipients or a passphrase.
+func Marshal(b *InventoryBackup, compression Compression, keys *Keys) ([]byte, error) {
+	payload, err := Encode(b)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(payload)
+	data, err := json.Marshal(&envelope{Checksum: checksumPrefix + hex.EncodeToString(sum[:]), Backup: payload})
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal backup data: %v", err)
+	}
+	return pack(data, compression, keys)
+}
+
+// MarshalNDJSON encodes a backup as a stream of typed records, compressing and
+// encrypting it like Marshal.
+func MarshalNDJSON(b *InventoryBackup, compression Compression, keys *Keys) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := writeNDJSON(buf, b, compression, keys)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeNDJSON streams a backup as NDJSON records to w, compressing and
+// encrypting it as it is written.
+func writeNDJSON(w io.Writer, b *InventoryBackup, compression Compression, keys *Keys) error {
+	ew := io.WriteCloser(nopWriteCloser{w})
+	if keys.encrypts() {
+		var err error
+		ew, err = encryptingWriter(w, keys)
+		if err != nil {
+			return err
+		}
+	}
+
+	cw, err := compressingWriter(ew, compression)
+	if err != nil {
+		return fmt.Errorf("unable to compress backup: %v", err)
+	}
+
+	bw := bufio.NewWriter(cw)
+	err = EncodeNDJSON(bw, b)
+	if err != nil {
+		return err
+	}
+
+	err = bw.Flush()
+	if err == nil {
+		err = cw.Close()
+	}
+	if err == nil {
+		err = ew.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write backup: %v", err)
+	}
+	return nil
+}
+
+// pack compresses and encrypts encoded backup data.
+func pack(data []byte, compression Compression, keys *Keys) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w, err := compressingWriter(buf, compression)
+	if err != nil {
+		return nil, fmt.Errorf("unable to compress backup: %v", err)
+	}
+
+	_, err = w.Write(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to compress backup: %v", err)
+	}
+
+	err = w.Close()
+	if err != nil {
+		return nil, fmt.Errorf("unable to compress backup: %v", err)
+	}
+
+	if keys.encrypts() {
+		return encrypt(buf.Bytes(), keys)
+	}
+	return buf.Bytes(), nil
+}
+
+// ReadFile reads a backup from a file, keys are only required if it is encrypted.
+func ReadFile(filename string, keys *Keys) (*InventoryBackup, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f, keys)
+}
+
+// WriteFile writes a backup to a file, formatted and compressed according to
+// the extension of the filename and encrypted if keys are provided.  The
+// backup is written to a temporary file that replaces the destination once it
+// has been completely written.  NDJSON backups are streamed to the file.
+func WriteFile(filename string, b *InventoryBackup, keys *Keys) error {
+	compression := CompressionForFile(filename)
+	if FormatForFile(filename) == FormatNDJSON {
+		return streamFileAtomic(filename, func(w io.Writer) error {
+			return writeNDJSON(w, b, compression, keys)
+		})
+	}
+
+	data, err := Marshal(b, compression, keys)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filename, data)
+}
+
+func writeFileAtomic(filename string, data []byte) error {
+	return streamFileAtomic(filename, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// streamFileAtomic replaces a file with the data written by write, once it
+// has completed without an error.
+func streamFileAtomic(filename string, write func(w io.Writer) error) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), "."+filepath.Base(filename)+".")
+	if err != nil {
+		return fmt.Errorf("unable to create temporary file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	err = write(tmp)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write temporary file: %v", err)
+	}
+
+	err = os.Rename(tmp.Name(), filename)
+	if err != nil {
+		return fmt.Errorf("unable to replace %s: %v", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package backup
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+func TestWriteReadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	b := &InventoryBackup{Nodes: []*types.Node{{InventoryID: "tst-0001"}}}
+	for _, name := range []string{"backup.json", "backup.json.gz", "backup.json.zst"} {
+		filename := filepath.Join(dir, name)
+
+		// a longer existing file must be replaced, not overwritten in place
+		err = ioutil.WriteFile(filename, bytes.Repeat([]byte(" "), 4096), 0600)
+		if err != nil {
+			t.Fatalf("unable to write existing file: %v", err)
+		}
+
+		err = WriteFile(filename, b, nil)
+		if err != nil {
+			t.Fatalf("unable to write %s: %v", name, err)
+		}
+
+		data, _ := ioutil.ReadFile(filename)
+		if detectCompression(data) != CompressionForFile(name) {
+			t.Errorf("%s: expected %s compression", name, CompressionForFile(name))
+		}
+
+		restored, err := ReadFile(filename, nil)
+		if err != nil {
+			t.Fatalf("unable to read %s: %v", name, err)
+		}
+		if len(restored.Nodes) != 1 || restored.Nodes[0].ID() != "tst-0001" {
+			t.Errorf("%s: unexpected nodes %v", name, restored.Nodes)
+		}
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 3 {
+		t.Errorf("expected temporary files to be cleaned up, found %d files", len(files))
+	}
+}
+
+func TestUnmarshalChecksum(t *testing.T) {
+	data, err := Marshal(&InventoryBackup{Nodes: []*types.Node{{InventoryID: "tst-0001"}}}, CompressionNone, nil)
+	if err != nil {
+		t.Fatalf("unable to marshal backup: %v", err)
+	}
+
+	corrupt := bytes.Replace(data, []byte("tst-0001"), []byte("tst-0002"), 1)
+	if _, err := Unmarshal(corrupt, nil); err == nil {
+		t.Errorf("expected checksum error for corrupted backup")
+	}
+
+	if _, err := Unmarshal([]byte(`{"Nodes":[{"InventoryID":"tst-0001"}]}`), nil); err != nil {
+		t.Errorf("unable to read backup without checksum: %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package backup
+
+import (
+	"fmt"
+
+	"github.com/PolarGeospatialCenter/inventory-cli/pkg/selector"
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+// ParseKind validates the name of an object kind.
+func ParseKind(name string) (Kind, error) {
+	for _, kind := range Kinds {
+		if string(kind) == name {
+			return kind, nil
+		}
+	}
+	return "", fmt.Errorf("unknown kind '%s', must be one of %v", name, Kinds)
+}
+
+// Filter selects part of a backup.  Each field that is set restricts the
+// objects selected, empty fields match everything.
+//
+// Systems, Roles and Environments select nodes by their attributes, Systems
+// also selects system objects by ID.  IDs match objects of any kind and
+// Selector only selects nodes.  When nodes are selected, only the systems and
+// networks they reference or that are named by Systems or IDs are included,
+// and IP reservations are selected along with the node that owns their MAC
+// address.
+type Filter struct {
+	Kinds        []Kind
+	Systems      []string
+	Roles        []string
+	Environments []string
+	IDs          []string
+	Selector     selector.Selector
+}
+
+func matches(values []string, value string) bool {
+	return len(values) == 0 || containsString(values, value)
+}
+
+func (f Filter) selectsNodes() bool {
+	return len(f.Systems) > 0 || len(f.Roles) > 0 || len(f.Environments) > 0 || len(f.IDs) > 0 || !f.Selector.Empty()
+}
+
+func (f Filter) includesKind(kind Kind) bool {
+	if len(f.Kinds) == 0 {
+		return true
+	}
+	for _, k := range f.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchNode returns true if the node is selected by the filter.
+func (f Filter) MatchNode(node *types.Node) bool {
+	return matches(f.IDs, node.ID()) && matches(f.Systems, node.System) &&
+		matches(f.Roles, node.Role) && matches(f.Environments, node.Environment) &&
+		f.Selector.Matches(node)
+}
+
+// Apply returns a copy of the backup that only contains the objects selected
+// by the filter.
+func (f Filter) Apply(b *InventoryBackup) *InventoryBackup {
+	filtered := *b
+	filtered.Nodes = []*types.Node{}
+	filtered.Systems = []*types.System{}
+	filtered.Networks = []*types.Network{}
+	filtered.IPReservations = types.IPReservationList{}
+
+	selectedMACs := make(map[string]bool)
+	referencedSystems := make(map[string]bool)
+	referencedNetworks := make(map[string]bool)
+	systems := b.Objects(KindSystem)
+	for _, node := range b.Nodes {
+		if !f.MatchNode(node) {
+			continue
+		}
+		for _, mac := range nodeMACs(node) {
+			selectedMACs[mac] = true
+		}
+		referencedSystems[node.System] = true
+		for network := range node.Networks {
+			referencedNetworks[network] = true
+		}
+		if system, ok := systems[node.System]; ok {
+			if env, ok := system.(*types.System).Environments[node.Environment]; ok && env != nil {
+				for _, network := range env.Networks {
+					referencedNetworks[network] = true
+				}
+			}
+		}
+		if f.includesKind(KindNode) {
+			filtered.Nodes = append(filtered.Nodes, node)
+		}
+	}
+
+	if f.includesKind(KindSystem) {
+		for _, system := range b.Systems {
+			id := system.ID()
+			if !f.selectsNodes() || containsString(f.IDs, id) || containsString(f.Systems, id) || referencedSystems[id] {
+				filtered.Systems = append(filtered.Systems, system)
+			}
+		}
+	}
+
+	if f.includesKind(KindNetwork) {
+		for _, network := range b.Networks {
+			id := network.ID()
+			if !f.selectsNodes() || containsString(f.IDs, id) || referencedNetworks[id] {
+				filtered.Networks = append(filtered.Networks, network)
+			}
+		}
+	}
+
+	if f.includesKind(KindIPReservation) {
+		for _, reservation := range b.IPReservations {
+			r := Reservation{reservation}
+			if !f.selectsNodes() || containsString(f.IDs, r.ID()) || (r.MAC != nil && selectedMACs[r.MAC.String()]) {
+				filtered.IPReservations = append(filtered.IPReservations, reservation)
+			}
+		}
+	}
+	return &filtered
+}
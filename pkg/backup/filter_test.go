@@ -0,0 +1,66 @@
+package backup
+
+import (
+	"net"
+	"testing"
+
+	"github.com/PolarGeospatialCenter/inventory-cli/pkg/selector"
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+func TestFilterApply(t *testing.T) {
+	mac, _ := net.ParseMAC("00:01:02:03:04:05")
+	b := &InventoryBackup{
+		Nodes: []*types.Node{
+			{InventoryID: "tst-0001", System: "tst", Role: "compute", Networks: types.NICInfoMap{"prod": {NICs: []net.HardwareAddr{mac}}}},
+			{InventoryID: "tst-0002", System: "tst", Role: "storage", Environment: "prod"},
+			{InventoryID: "oth-0001", System: "other", Role: "compute"},
+		},
+		Systems: []*types.System{
+			{Name: "tst", Environments: map[string]*types.Environment{"prod": {Networks: map[string]string{"mgmt": "mgmt"}}}},
+			{Name: "other"},
+			{Name: "unused"},
+		},
+		Networks: []*types.Network{{Name: "prod"}, {Name: "mgmt"}},
+		IPReservations: types.IPReservationList{
+			testReservation(t, "10.0.0.1/24", "00:01:02:03:04:05"),
+			testReservation(t, "10.0.0.2/24", "00:01:02:03:04:06"),
+		},
+	}
+
+	storage, err := selector.Parse("role=storage")
+	if err != nil {
+		t.Fatalf("unable to parse selector: %v", err)
+	}
+
+	cases := []struct {
+		filter   Filter
+		nodes    int
+		systems  int
+		networks int
+		ips      int
+	}{
+		{Filter{}, 3, 3, 2, 2},
+		{Filter{Systems: []string{"tst"}}, 2, 1, 2, 1},
+		{Filter{Systems: []string{"tst"}, Kinds: []Kind{KindNode}}, 2, 0, 0, 0},
+		{Filter{Roles: []string{"compute"}}, 2, 2, 1, 1},
+		{Filter{Environments: []string{"prod"}}, 1, 1, 1, 0},
+		{Filter{IDs: []string{"tst-0002"}}, 1, 1, 1, 0},
+		{Filter{IDs: []string{"mgmt"}}, 0, 0, 1, 0},
+		{Filter{Systems: []string{"unused"}}, 0, 1, 0, 0},
+		{Filter{Kinds: []Kind{KindNetwork, KindIPReservation}}, 0, 0, 2, 2},
+		{Filter{Selector: storage}, 1, 1, 1, 0},
+	}
+
+	for i, c := range cases {
+		filtered := c.filter.Apply(b)
+		if len(filtered.Nodes) != c.nodes || len(filtered.Systems) != c.systems || len(filtered.Networks) != c.networks || len(filtered.IPReservations) != c.ips {
+			t.Errorf("case %d: expected %d/%d/%d/%d objects, got %d/%d/%d/%d", i, c.nodes, c.systems, c.networks, c.ips,
+				len(filtered.Nodes), len(filtered.Systems), len(filtered.Networks), len(filtered.IPReservations))
+		}
+	}
+
+	if len(b.Nodes) != 3 {
+		t.Errorf("filter modified the original backup")
+	}
+}
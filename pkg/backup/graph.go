@@ -0,0 +1,185 @@
+package backup
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+)
+
+// ObjectRef identifies a single object in the inventory.
+type ObjectRef struct {
+	Kind Kind   `json:"kind"`
+	ID   string `json:"id"`
+}
+
+func (r ObjectRef) String() string {
+	return fmt.Sprintf("%s/%s", r.Kind, r.ID)
+}
+
+// kindOrder is used to break ties between objects without dependencies on each
+// other so that the order of a sort is stable.
+var kindOrder = map[Kind]int{
+	KindNetwork: 0,
+	KindSystem:  1,
+	KindNode:    2,
+
+	KindIPReservation: 3,
+}
+
+func lessRef(a, b ObjectRef) bool {
+	if a.Kind != b.Kind {
+		return kindOrder[a.Kind] < kindOrder[b.Kind]
+	}
+	return a.ID < b.ID
+}
+
+// DependencyGraph tracks which objects in a backup reference other objects in
+// the same backup.
+type DependencyGraph struct {
+	refs         []ObjectRef
+	dependencies map[ObjectRef]map[ObjectRef]bool
+}
+
+// NewDependencyGraph builds the graph of references between the objects in a
+// backup.  Nodes depend on their system and on every network they have an
+// interface on, systems depend on the networks used by their environments and
+// ip reservations depend on the node that owns their MAC and the network that
+// contains their IP.
+// References to objects that are not part of the backup are not included.
+func NewDependencyGraph(b *InventoryBackup) *DependencyGraph {
+	g := &DependencyGraph{dependencies: make(map[ObjectRef]map[ObjectRef]bool)}
+	for _, network := range b.Networks {
+		g.add(ObjectRef{KindNetwork, network.ID()})
+	}
+
+	for _, system := range b.Systems {
+		ref := ObjectRef{KindSystem, system.ID()}
+		g.add(ref)
+		for _, env := range system.Environments {
+			if env == nil {
+				continue
+			}
+			for _, network := range env.Networks {
+				g.addDependency(ref, ObjectRef{KindNetwork, network})
+			}
+		}
+	}
+
+	for _, node := range b.Nodes {
+		ref := ObjectRef{KindNode, node.ID()}
+		g.add(ref)
+		if node.System != "" {
+			g.addDependency(ref, ObjectRef{KindSystem, node.System})
+		}
+		for network := range node.Networks {
+			g.addDependency(ref, ObjectRef{KindNetwork, network})
+		}
+	}
+
+	macs := make(map[string]ObjectRef)
+	for _, node := range b.Nodes {
+		for _, iface := range node.Networks {
+			if iface == nil {
+				continue
+			}
+			for _, mac := range iface.NICs {
+				macs[mac.String()] = ObjectRef{KindNode, node.ID()}
+			}
+		}
+	}
+
+	for _, reservation := range b.IPReservations {
+		r := Reservation{reservation}
+		ref := ObjectRef{KindIPReservation, r.ID()}
+		g.add(ref)
+		if node, ok := macs[r.MAC.String()]; ok && r.MAC != nil {
+			g.addDependency(ref, node)
+		}
+		for _, network := range b.Networks {
+			if r.IP != nil && subnetContaining(network, r.IP.IP) != nil {
+				g.addDependency(ref, ObjectRef{KindNetwork, network.ID()})
+			}
+		}
+	}
+
+	// drop references to objects outside of the backup
+	for _, deps := range g.dependencies {
+		for dep := range deps {
+			if _, ok := g.dependencies[dep]; !ok {
+				delete(deps, dep)
+			}
+		}
+	}
+	return g
+}
+
+func (g *DependencyGraph) add(ref ObjectRef) {
+	if _, ok := g.dependencies[ref]; ok {
+		return
+	}
+	g.refs = append(g.refs, ref)
+	g.dependencies[ref] = make(map[ObjectRef]bool)
+}
+
+func (g *DependencyGraph) addDependency(ref, dependency ObjectRef) {
+	g.dependencies[ref][dependency] = true
+}
+
+// Sort returns the objects in the graph ordered so that every object comes
+// after all of the objects it depends on.
+func (g *DependencyGraph) Sort() ([]ObjectRef, error) {
+	remaining := make(map[ObjectRef]int, len(g.refs))
+	dependents := make(map[ObjectRef][]ObjectRef, len(g.refs))
+	for _, ref := range g.refs {
+		remaining[ref] = len(g.dependencies[ref])
+		for dep := range g.dependencies[ref] {
+			dependents[dep] = append(dependents[dep], ref)
+		}
+	}
+
+	ready := &refHeap{}
+	for _, ref := range g.refs {
+		if remaining[ref] == 0 {
+			heap.Push(ready, ref)
+		}
+	}
+
+	sorted := make([]ObjectRef, 0, len(g.refs))
+	for ready.Len() > 0 {
+		ref := heap.Pop(ready).(ObjectRef)
+		sorted = append(sorted, ref)
+		for _, dependent := range dependents[ref] {
+			remaining[dependent]--
+			if remaining[dependent] == 0 {
+				heap.Push(ready, dependent)
+			}
+		}
+	}
+
+	if len(sorted) != len(g.refs) {
+		cycle := []string{}
+		for _, ref := range g.refs {
+			if remaining[ref] > 0 {
+				cycle = append(cycle, ref.String())
+			}
+		}
+		sort.Strings(cycle)
+		return nil, fmt.Errorf("dependency cycle between %v", cycle)
+	}
+	return sorted, nil
+}
+
+// refHeap is a min-heap of object references, used to pick the next object
+// during a sort.
+type refHeap []ObjectRef
+
+func (h refHeap) Len() int            { return len(h) }
+func (h refHeap) Less(i, j int) bool  { return lessRef(h[i], h[j]) }
+func (h refHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *refHeap) Push(x interface{}) { *h = append(*h, x.(ObjectRef)) }
+func (h *refHeap) Pop() interface{} {
+	old := *h
+	ref := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return ref
+}
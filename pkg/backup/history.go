@@ -0,0 +1,150 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+// nodeVersion holds the attributes of a node that are tracked in its history.
+type nodeVersion struct {
+	System      string
+	Role        string
+	Environment string
+	Location    *types.ChassisLocation
+	SubIndex    string
+	Networks    types.NICInfoMap
+	Metadata    types.Metadata
+}
+
+func newNodeVersion(node *types.Node) *nodeVersion {
+	return &nodeVersion{
+		System:      node.System,
+		Role:        node.Role,
+		Environment: node.Environment,
+		Location:    node.ChassisLocation,
+		SubIndex:    node.ChassisSubIndex,
+		Networks:    node.Networks,
+		Metadata:    node.Metadata,
+	}
+}
+
+// Version is a distinct version of an object and the snapshot it first
+// appeared in.  Object is nil if the object was removed in the snapshot.
+type Version struct {
+	Snapshot *Snapshot     `json:"snapshot"`
+	Object   Object        `json:"object"`
+	Changes  []FieldChange `json:"changes,omitempty"`
+}
+
+// History lists the versions of an object, oldest first.
+type History struct {
+	Kind     Kind       `json:"kind"`
+	ID       string     `json:"id"`
+	Versions []*Version `json:"versions"`
+}
+
+// NodeHistory reads the snapshots in dir in date order and returns every
+// distinct version of a node's system, role, environment, location, network
+// interfaces and metadata.
+func NodeHistory(dir string, id string, keys *Keys) (*History, error) {
+	snapshots, err := ReadIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &History{Kind: KindNode, ID: id, Versions: []*Version{}}
+	var previous *nodeVersion
+	var seen bool
+	for _, snapshot := range snapshots {
+		b, err := ReadFile(filepath.Join(dir, snapshot.Filename), keys)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read snapshot %s: %v", snapshot.Filename, err)
+		}
+
+		obj, ok := b.Objects(KindNode)[id]
+		if !ok {
+			if previous != nil {
+				history.Versions = append(history.Versions, &Version{Snapshot: snapshot})
+			}
+			previous = nil
+			continue
+		}
+
+		node := obj.(*types.Node)
+		current := newNodeVersion(node)
+		if previous == nil {
+			history.Versions = append(history.Versions, &Version{Snapshot: snapshot, Object: node})
+			previous = current
+			seen = true
+			continue
+		}
+
+		changes, err := Diff(previous, current)
+		if err != nil {
+			return nil, fmt.Errorf("unable to compare node %s: %v", id, err)
+		}
+		if len(changes) > 0 {
+			history.Versions = append(history.Versions, &Version{Snapshot: snapshot, Object: node, Changes: changes})
+		}
+		previous = current
+	}
+
+	if !seen {
+		return nil, fmt.Errorf("node %s isn't in any of the %d snapshots", id, len(snapshots))
+	}
+	return history, nil
+}
+
+// Print writes a human readable version of the history to w.
+func (h *History) Print(w io.Writer) {
+	for i, version := range h.Versions {
+		date := version.Snapshot.Date.Local().Format(time.RFC3339)
+		switch {
+		case version.Object == nil:
+			fmt.Fprintf(w, "%s %s: removed\n", date, version.Snapshot.Filename)
+		case i == 0 || h.Versions[i-1].Object == nil:
+			fmt.Fprintf(w, "%s %s: added\n", date, version.Snapshot.Filename)
+			if node, ok := version.Object.(*types.Node); ok {
+				printNodeVersion(w, newNodeVersion(node))
+			}
+		default:
+			fmt.Fprintf(w, "%s %s: changed\n", date, version.Snapshot.Filename)
+			for _, change := range version.Changes {
+				fmt.Fprintf(w, "    %s\n", change)
+			}
+		}
+	}
+}
+
+func printNodeVersion(w io.Writer, v *nodeVersion) {
+	for _, field := range []struct{ name, value string }{{"System", v.System}, {"Role", v.Role}, {"Environment", v.Environment}} {
+		if field.value != "" {
+			fmt.Fprintf(w, "    %s: %s\n", field.name, field.value)
+		}
+	}
+	if v.Location != nil {
+		fmt.Fprintf(w, "    Location: %s %s %s U%d\n", v.Location.Building, v.Location.Room, v.Location.Rack, v.Location.BottomU)
+	}
+	for _, network := range sortedKeys(v.Networks) {
+		if iface := v.Networks[network]; iface != nil {
+			fmt.Fprintf(w, "    Network %s: %v\n", network, iface.NICs)
+		}
+	}
+	for _, key := range sortedMetadataKeys(v.Metadata) {
+		fmt.Fprintf(w, "    Metadata %s: %v\n", key, v.Metadata[key])
+	}
+}
+
+func sortedMetadataKeys(metadata types.Metadata) []string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
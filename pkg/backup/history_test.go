@@ -0,0 +1,73 @@
+package backup
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+func TestNodeHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshots")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	date := time.Date(2019, 3, 10, 0, 0, 0, 0, time.UTC)
+	racks := []string{"aa01", "aa01", "aa02", "", "aa02"}
+	for i, rack := range racks {
+		b := &InventoryBackup{BackupDate: date.Add(time.Duration(i) * time.Hour), Nodes: []*types.Node{}}
+		if rack != "" {
+			// LastUpdated changes don't create a new version
+			b.Nodes = append(b.Nodes, &types.Node{
+				InventoryID:     "tst-0001",
+				ChassisLocation: &types.ChassisLocation{Building: "bldg", Room: "room", Rack: rack, BottomU: 12},
+				LastUpdated:     b.BackupDate,
+			})
+		}
+		if _, err = WriteSnapshot(dir, b, CompressionNone, nil); err != nil {
+			t.Fatalf("unable to write snapshot: %v", err)
+		}
+	}
+
+	history, err := NodeHistory(dir, "tst-0001", nil)
+	if err != nil {
+		t.Fatalf("unable to read history: %v", err)
+	}
+
+	expected := []string{
+		"inventory-20190310T000000Z.json",
+		"inventory-20190310T020000Z.json",
+		"inventory-20190310T030000Z.json",
+		"inventory-20190310T040000Z.json",
+	}
+	if len(history.Versions) != len(expected) {
+		t.Fatalf("expected %d versions, got %d", len(expected), len(history.Versions))
+	}
+	for i, version := range history.Versions {
+		if version.Snapshot.Filename != expected[i] {
+			t.Errorf("expected version %d from %s, got %s", i, expected[i], version.Snapshot.Filename)
+		}
+	}
+	if changes := history.Versions[1].Changes; len(changes) != 1 || changes[0].Field != "Location.Rack" {
+		t.Errorf("unexpected changes for rack move: %v", changes)
+	}
+	if history.Versions[2].Object != nil {
+		t.Errorf("expected node to be removed in %s", expected[2])
+	}
+
+	out := &bytes.Buffer{}
+	history.Print(out)
+	if !strings.Contains(out.String(), `Location.Rack: "aa01" -> "aa02"`) {
+		t.Errorf("history output is missing rack move:\n%s", out.String())
+	}
+
+	if _, err = NodeHistory(dir, "tst-0002", nil); err == nil {
+		t.Errorf("expected an error for a node that was never backed up")
+	}
+}
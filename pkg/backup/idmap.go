@@ -0,0 +1,125 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// IDMap maps the old ID of an object to a new ID, per kind.
+type IDMap map[Kind]map[string]string
+
+// ParseIDMap reads an ID map from YAML or JSON, keyed by kind:
+//
+//	system:
+//	  tst: lab
+//	network:
+//	  tst-prod: lab-prod
+func ParseIDMap(data []byte) (IDMap, error) {
+	raw := map[string]map[string]string{}
+	err := yaml.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse id map: %v", err)
+	}
+
+	m := make(IDMap)
+	for name, ids := range raw {
+		kind, err := ParseKind(name)
+		if err != nil {
+			return nil, err
+		}
+		if kind == KindIPReservation {
+			return nil, fmt.Errorf("ip reservations can't be remapped")
+		}
+		m[kind] = ids
+	}
+	return m, nil
+}
+
+// ReadIDMap reads an ID map from a YAML or JSON file.
+func ReadIDMap(filename string) (IDMap, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIDMap(data)
+}
+
+func (m IDMap) id(kind Kind, id string) string {
+	if newID, ok := m[kind][id]; ok {
+		return newID
+	}
+	return id
+}
+
+// Apply returns a copy of the backup with objects renamed according to the
+// map.  References to renamed systems and networks from nodes and systems are
+// rewritten, as are tombstones.
+func (m IDMap) Apply(b *InventoryBackup) (*InventoryBackup, error) {
+	mapped, err := copyBackup(b)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, network := range mapped.Networks {
+		network.Name = m.id(KindNetwork, network.Name)
+	}
+
+	for _, system := range mapped.Systems {
+		if system.ShortName != "" {
+			system.ShortName = m.id(KindSystem, system.ShortName)
+		} else {
+			system.Name = m.id(KindSystem, system.Name)
+		}
+		for _, environment := range system.Environments {
+			if environment == nil {
+				continue
+			}
+			for name, network := range environment.Networks {
+				environment.Networks[name] = m.id(KindNetwork, network)
+			}
+		}
+	}
+
+	for _, node := range mapped.Nodes {
+		node.InventoryID = m.id(KindNode, node.InventoryID)
+		if node.System != "" {
+			node.System = m.id(KindSystem, node.System)
+		}
+		networks := make(types.NICInfoMap, len(node.Networks))
+		for name, iface := range node.Networks {
+			networks[m.id(KindNetwork, name)] = iface
+		}
+		if node.Networks != nil {
+			node.Networks = networks
+		}
+	}
+
+	for i, ref := range mapped.Tombstones {
+		mapped.Tombstones[i].ID = m.id(ref.Kind, ref.ID)
+	}
+
+	if duplicates := duplicateIDs(mapped); len(duplicates) > 0 {
+		return nil, fmt.Errorf("%s %s is used by more than one object after remapping", duplicates[0].Kind, duplicates[0].ID)
+	}
+	return mapped, nil
+}
+
+func copyBackup(b *InventoryBackup) (*InventoryBackup, error) {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return nil, fmt.Errorf("unable to copy backup: %v", err)
+	}
+
+	copied := &InventoryBackup{}
+	err = json.Unmarshal(data, copied)
+	if err != nil {
+		return nil, fmt.Errorf("unable to copy backup: %v", err)
+	}
+	copied.MigratedFrom = b.MigratedFrom
+	copied.Unverified = b.Unverified
+	return copied, nil
+}
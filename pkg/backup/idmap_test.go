@@ -0,0 +1,69 @@
+package backup
+
+import (
+	"testing"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+func TestIDMapApply(t *testing.T) {
+	m, err := ParseIDMap([]byte(`
+system:
+  tst: lab
+network:
+  tst-prod: lab-prod
+node:
+  tst-0001: lab-0001
+`))
+	if err != nil {
+		t.Fatalf("unable to parse id map: %v", err)
+	}
+
+	b := &InventoryBackup{
+		Nodes: []*types.Node{
+			{InventoryID: "tst-0001", System: "tst", Networks: types.NICInfoMap{"tst-prod": &types.NetworkInterface{}, "mgmt": &types.NetworkInterface{}}},
+		},
+		Systems: []*types.System{
+			{Name: "Test System", ShortName: "tst", Environments: map[string]*types.Environment{"prod": {Networks: map[string]string{"provisioning": "tst-prod"}}}},
+		},
+		Networks:   []*types.Network{{Name: "tst-prod"}, {Name: "mgmt"}},
+		Tombstones: []ObjectRef{{KindNode, "tst-0001"}},
+	}
+
+	mapped, err := m.Apply(b)
+	if err != nil {
+		t.Fatalf("unable to apply id map: %v", err)
+	}
+
+	node := mapped.Nodes[0]
+	if node.InventoryID != "lab-0001" || node.System != "lab" {
+		t.Errorf("node wasn't remapped: %s in %s", node.InventoryID, node.System)
+	}
+	if _, ok := node.Networks["lab-prod"]; !ok || len(node.Networks) != 2 {
+		t.Errorf("node networks weren't remapped: %v", sortedKeys(node.Networks))
+	}
+	if system := mapped.Systems[0]; system.ID() != "lab" || system.Name != "Test System" || system.Environments["prod"].Networks["provisioning"] != "lab-prod" {
+		t.Errorf("system wasn't remapped: %+v", system)
+	}
+	if mapped.Networks[0].Name != "lab-prod" || mapped.Networks[1].Name != "mgmt" {
+		t.Errorf("networks weren't remapped: %s, %s", mapped.Networks[0].Name, mapped.Networks[1].Name)
+	}
+	if mapped.Tombstones[0].ID != "lab-0001" {
+		t.Errorf("tombstone wasn't remapped: %s", mapped.Tombstones[0])
+	}
+	if b.Nodes[0].InventoryID != "tst-0001" || b.Nodes[0].System != "tst" {
+		t.Errorf("original backup was modified")
+	}
+
+	m[KindNetwork]["tst-prod"] = "mgmt"
+	if _, err = m.Apply(b); err == nil {
+		t.Errorf("expected an error when two networks are mapped to the same id")
+	}
+
+	if _, err = ParseIDMap([]byte(`{"ipreservation": {"10.0.0.1/24": "10.0.1.1/24"}}`)); err == nil {
+		t.Errorf("expected an error remapping ip reservations")
+	}
+	if _, err = ParseIDMap([]byte(`{"rack": {"aa01": "aa02"}}`)); err == nil {
+		t.Errorf("expected an error for an unknown kind")
+	}
+}
@@ -0,0 +1,151 @@
+package backup
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+// Incremental returns a backup of the objects in current whose timestamp isn't
+// before since.  If base is provided, tombstones are added for the objects in
+// base that no longer exist in current.  IP reservations don't have a
+// timestamp, so all of them are included.
+func Incremental(current *InventoryBackup, since time.Time, base *InventoryBackup) *InventoryBackup {
+	incremental := &InventoryBackup{
+		BackupDate:     current.BackupDate,
+		Since:          &since,
+		Nodes:          []*types.Node{},
+		Systems:        []*types.System{},
+		Networks:       []*types.Network{},
+		IPReservations: current.IPReservations,
+		Tombstones:     []ObjectRef{},
+	}
+
+	for _, node := range current.Nodes {
+		if node.Timestamp() >= since.Unix() {
+			incremental.Nodes = append(incremental.Nodes, node)
+		}
+	}
+	for _, system := range current.Systems {
+		if system.Timestamp() >= since.Unix() {
+			incremental.Systems = append(incremental.Systems, system)
+		}
+	}
+	for _, network := range current.Networks {
+		if network.Timestamp() >= since.Unix() {
+			incremental.Networks = append(incremental.Networks, network)
+		}
+	}
+
+	if base != nil {
+		for _, kind := range []Kind{KindNetwork, KindSystem, KindNode} {
+			currentObjects := current.Objects(kind)
+			baseObjects := base.Objects(kind)
+			for _, id := range sortedIDs(baseObjects) {
+				if _, ok := currentObjects[id]; !ok {
+					incremental.Tombstones = append(incremental.Tombstones, ObjectRef{kind, id})
+				}
+			}
+		}
+	}
+	return incremental
+}
+
+func sortedIDs(objects map[string]Object) []string {
+	ids := make([]string, 0, len(objects))
+	for id := range objects {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Merge applies an incremental backup on top of base, returning the state of
+// the inventory at the time the incremental backup was taken.
+func Merge(base, incremental *InventoryBackup) (*InventoryBackup, error) {
+	if incremental.Since == nil {
+		return nil, fmt.Errorf("backup taken at %s isn't incremental", incremental.BackupDate.Format(time.RFC3339))
+	}
+
+	if incremental.Since.After(base.BackupDate) {
+		return nil, fmt.Errorf("incremental backup starts at %s, after the previous backup was taken at %s",
+			incremental.Since.Format(time.RFC3339), base.BackupDate.Format(time.RFC3339))
+	}
+
+	deleted := make(map[ObjectRef]bool)
+	for _, ref := range base.Tombstones {
+		deleted[ref] = true
+	}
+	for _, ref := range incremental.Tombstones {
+		deleted[ref] = true
+	}
+
+	merged := &InventoryBackup{
+		SchemaVersion:  incremental.SchemaVersion,
+		BackupDate:     incremental.BackupDate,
+		Since:          base.Since,
+		IPReservations: incremental.IPReservations,
+		Unverified:     base.Unverified + incremental.Unverified,
+	}
+
+	for _, kind := range []Kind{KindNetwork, KindSystem, KindNode} {
+		objects := base.Objects(kind)
+		for id, obj := range incremental.Objects(kind) {
+			objects[id] = obj
+			delete(deleted, ObjectRef{kind, id})
+		}
+
+		for _, id := range sortedIDs(objects) {
+			if deleted[ObjectRef{kind, id}] {
+				continue
+			}
+			switch obj := objects[id].(type) {
+			case *types.Node:
+				merged.Nodes = append(merged.Nodes, obj)
+			case *types.System:
+				merged.Systems = append(merged.Systems, obj)
+			case *types.Network:
+				merged.Networks = append(merged.Networks, obj)
+			}
+		}
+	}
+
+	// tombstones are only kept if the chain doesn't start with a full backup
+	if merged.Since != nil {
+		for _, ref := range append(base.Tombstones, incremental.Tombstones...) {
+			if deleted[ref] {
+				merged.Tombstones = append(merged.Tombstones, ref)
+				delete(deleted, ref)
+			}
+		}
+	}
+	return merged, nil
+}
+
+// ReadChain reads a backup followed by a chain of incremental backups, each
+// based on the one before it, and merges them.
+func ReadChain(filenames []string, keys *Keys) (*InventoryBackup, error) {
+	if len(filenames) == 0 {
+		return nil, fmt.Errorf("no backup files specified")
+	}
+
+	merged, err := ReadFile(filenames[0], keys)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %v", filenames[0], err)
+	}
+
+	for _, filename := range filenames[1:] {
+		incremental, err := ReadFile(filename, keys)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %v", filename, err)
+		}
+
+		merged, err = Merge(merged, incremental)
+		if err != nil {
+			return nil, fmt.Errorf("unable to apply %s: %v", filename, err)
+		}
+	}
+	return merged, nil
+}
@@ -0,0 +1,62 @@
+package backup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+func TestIncrementalMerge(t *testing.T) {
+	t0 := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(24 * time.Hour)
+
+	full := &InventoryBackup{
+		BackupDate: t0,
+		Nodes: []*types.Node{
+			{InventoryID: "tst-0001", Role: "compute", LastUpdated: t0.Add(-time.Hour)},
+			{InventoryID: "tst-0002", Role: "compute", LastUpdated: t0.Add(-time.Hour)},
+			{InventoryID: "tst-0003", Role: "compute", LastUpdated: t0.Add(-time.Hour)},
+		},
+		Systems: []*types.System{{Name: "tst", LastUpdated: t0.Add(-time.Hour)}},
+	}
+
+	current := &InventoryBackup{
+		BackupDate: t1,
+		Nodes: []*types.Node{
+			{InventoryID: "tst-0001", Role: "compute", LastUpdated: t0.Add(-time.Hour)},
+			{InventoryID: "tst-0002", Role: "storage", LastUpdated: t0.Add(time.Hour)},
+			{InventoryID: "tst-0004", Role: "compute", LastUpdated: t0.Add(time.Hour)},
+		},
+		Systems: []*types.System{{Name: "tst", LastUpdated: t0.Add(-time.Hour)}},
+	}
+
+	incremental := Incremental(current, full.BackupDate, full)
+	if len(incremental.Nodes) != 2 || len(incremental.Systems) != 0 {
+		t.Errorf("expected 2 changed nodes and no systems, got %d nodes and %d systems", len(incremental.Nodes), len(incremental.Systems))
+	}
+	if len(incremental.Tombstones) != 1 || incremental.Tombstones[0] != (ObjectRef{KindNode, "tst-0003"}) {
+		t.Errorf("unexpected tombstones: %v", incremental.Tombstones)
+	}
+
+	merged, err := Merge(full, incremental)
+	if err != nil {
+		t.Fatalf("unable to merge incremental backup: %v", err)
+	}
+
+	changeset, err := Compare(current, merged)
+	if err != nil {
+		t.Fatalf("unable to compare merged backup: %v", err)
+	}
+	if len(changeset.Changes) != 0 {
+		t.Errorf("merged backup doesn't match current state: %v", changeset.Changes)
+	}
+	if merged.Since != nil || len(merged.Tombstones) != 0 || !merged.BackupDate.Equal(t1) {
+		t.Errorf("expected a full backup taken at %s after merge", t1)
+	}
+
+	gap := Incremental(current, t1, nil)
+	if _, err := Merge(full, gap); err == nil {
+		t.Errorf("expected error merging incremental backup that starts after the base backup")
+	}
+}
@@ -0,0 +1,91 @@
+package backup
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+// Reservation wraps an ip reservation so that it can be planned and compared
+// like the other inventory objects.  Reservations are identified by their IP.
+type Reservation struct {
+	*types.IPReservation
+}
+
+func (r Reservation) ID() string {
+	if r.IP == nil {
+		return ""
+	}
+	return r.IP.IP.String()
+}
+
+// Timestamp returns the start of the reservation, reservations don't track
+// when they were last modified.
+func (r Reservation) Timestamp() int64 {
+	if r.Start == nil {
+		return 0
+	}
+	return r.Start.Unix()
+}
+
+// Expired returns true if the reservation ended before t.
+func (r Reservation) Expired(t time.Time) bool {
+	return r.End != nil && r.End.Before(t)
+}
+
+// AddReservations adds reservations to the backup, skipping any IP that is
+// already reserved in it.
+func (b *InventoryBackup) AddReservations(reservations ...*types.IPReservation) {
+	existing := b.Objects(KindIPReservation)
+	for _, reservation := range reservations {
+		if reservation.IP == nil {
+			continue
+		}
+		if _, ok := existing[Reservation{reservation}.ID()]; ok {
+			continue
+		}
+		existing[Reservation{reservation}.ID()] = Reservation{reservation}
+		b.IPReservations = append(b.IPReservations, reservation)
+	}
+}
+
+// planReservation checks a reservation against the live reservation for the
+// same IP before it is planned like any other object.  A live reservation
+// for a different MAC is a collision that can't be resolved by an import.
+func planReservation(r Reservation, liveObj Object, policy ConflictPolicy, now time.Time) (*PlanItem, error) {
+	if liveObj != nil {
+		live := liveObj.(Reservation)
+		if live.MAC.String() != r.MAC.String() {
+			reason := fmt.Sprintf("ip is already allocated to %s", live.MAC)
+			return &PlanItem{Kind: KindIPReservation, ID: r.ID(), Action: ActionBlocked, Reason: reason, Object: r}, nil
+		}
+	} else if r.Expired(now) {
+		return &PlanItem{Kind: KindIPReservation, ID: r.ID(), Action: ActionSkipExpired, Object: r}, nil
+	}
+	return planObject(KindIPReservation, r, liveObj, policy)
+}
+
+func checkReservationReferences(r Reservation, networks map[string]Object) error {
+	if r.IP == nil {
+		return fmt.Errorf("reservation doesn't have an ip")
+	}
+	for _, obj := range networks {
+		if subnetContaining(obj.(*types.Network), r.IP.IP) != nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("no network in the backup or inventory contains %s", r.ID())
+}
+
+// subnetContaining returns the subnet of the network that contains ip,
+// ignoring subnets without a cidr.
+func subnetContaining(network *types.Network, ip net.IP) *types.Subnet {
+	for _, subnet := range network.Subnets {
+		if subnet != nil && subnet.Cidr != nil && subnet.Cidr.Contains(ip) {
+			return subnet
+		}
+	}
+	return nil
+}
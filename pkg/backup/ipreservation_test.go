@@ -0,0 +1,77 @@
+package backup
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+func testReservation(t *testing.T, ip, mac string) *types.IPReservation {
+	addr, cidr, err := net.ParseCIDR(ip)
+	if err != nil {
+		t.Fatalf("invalid ip %s: %v", ip, err)
+	}
+	cidr.IP = addr
+	hwAddr, err := net.ParseMAC(mac)
+	if err != nil {
+		t.Fatalf("invalid mac %s: %v", mac, err)
+	}
+	return &types.IPReservation{IP: cidr, MAC: hwAddr, Metadata: types.Metadata{}}
+}
+
+func TestNewPlanReservations(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("10.0.0.0/24")
+	mac, _ := net.ParseMAC("00:01:02:03:04:05")
+	expired := time.Now().Add(-time.Hour)
+
+	expiredReservation := testReservation(t, "10.0.0.4/24", "00:01:02:03:04:05")
+	expiredReservation.End = &expired
+
+	backupData := &InventoryBackup{
+		Nodes: []*types.Node{
+			{InventoryID: "tst-0001", Networks: types.NICInfoMap{"prod": &types.NetworkInterface{NICs: []net.HardwareAddr{mac}}}},
+		},
+		// subnets without a cidr are reported by verify, they mustn't break planning
+		Networks: []*types.Network{{Name: "prod", Subnets: types.SubnetList{{}, {Cidr: subnet}}}},
+		IPReservations: types.IPReservationList{
+			testReservation(t, "10.0.0.1/24", "00:01:02:03:04:05"),
+			testReservation(t, "10.0.0.2/24", "00:01:02:03:04:05"),
+			testReservation(t, "10.1.0.3/24", "00:01:02:03:04:05"),
+			expiredReservation,
+		},
+	}
+	live := &InventoryBackup{
+		IPReservations: types.IPReservationList{testReservation(t, "10.0.0.2/24", "00:01:02:03:04:06")},
+	}
+
+	plan, err := NewPlan(backupData, live, PlanOptions{})
+	if err != nil {
+		t.Fatalf("unable to build plan: %v", err)
+	}
+
+	if plan.Items[0].Kind != KindNetwork || plan.Items[1].Kind != KindNode {
+		t.Errorf("expected network and node before reservations, got %s and %s", plan.Items[0].Kind, plan.Items[1].Kind)
+	}
+
+	expected := map[string]Action{
+		"10.0.0.1": ActionCreate,
+		"10.0.0.2": ActionBlocked,
+		"10.1.0.3": ActionBlocked,
+		"10.0.0.4": ActionSkipExpired,
+	}
+	for _, item := range plan.Items[2:] {
+		if item.Action != expected[item.ID] {
+			t.Errorf("expected %s for %s, got %s (%s)", expected[item.ID], item.ID, item.Action, item.Reason)
+		}
+	}
+}
+
+func TestAddReservations(t *testing.T) {
+	b := &InventoryBackup{}
+	b.AddReservations(testReservation(t, "10.0.0.1/24", "00:01:02:03:04:05"), testReservation(t, "10.0.0.1/24", "00:01:02:03:04:05"))
+	if len(b.IPReservations) != 1 {
+		t.Errorf("expected duplicate reservation to be dropped, got %d reservations", len(b.IPReservations))
+	}
+}
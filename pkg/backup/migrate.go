@@ -0,0 +1,107 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SchemaVersion is the version of the backup format written by this version
+// of the cli.  Any change to the format must increment it and register a
+// migration from the previous version.
+const SchemaVersion = 2
+
+// Migration upgrades a decoded backup document in place to the next schema version.
+type Migration func(doc map[string]interface{}) error
+
+// migrations are keyed by the schema version they upgrade from.
+var migrations = map[int]Migration{
+	1: migrateV1,
+}
+
+// migrateV1 upgrades backups written before the format was versioned, which
+// may not include ip reservations.
+func migrateV1(doc map[string]interface{}) error {
+	if _, ok := doc["IPReservations"]; !ok {
+		doc["IPReservations"] = []interface{}{}
+	}
+	return nil
+}
+
+// documentVersion returns the schema version of a decoded backup document,
+// documents without a version were written before the format was versioned.
+func documentVersion(doc map[string]interface{}) (int, error) {
+	raw, ok := doc["SchemaVersion"]
+	if !ok || raw == nil {
+		return 1, nil
+	}
+
+	version, ok := raw.(float64)
+	if !ok || version < 1 || version != float64(int(version)) {
+		return 0, fmt.Errorf("invalid schema version: %v", raw)
+	}
+	return int(version), nil
+}
+
+// Migrate upgrades a decoded backup document to the current schema version and
+// returns the version it was upgraded from.
+func Migrate(doc map[string]interface{}) (int, error) {
+	original, err := documentVersion(doc)
+	if err != nil {
+		return 0, err
+	}
+
+	if original > SchemaVersion {
+		return 0, fmt.Errorf("backup schema version %d is newer than the supported version %d", original, SchemaVersion)
+	}
+
+	for version := original; version < SchemaVersion; version++ {
+		migration, ok := migrations[version]
+		if !ok {
+			return 0, fmt.Errorf("no migration from schema version %d", version)
+		}
+
+		err = migration(doc)
+		if err != nil {
+			return 0, fmt.Errorf("unable to migrate from schema version %d: %v", version, err)
+		}
+		doc["SchemaVersion"] = version + 1
+	}
+	return original, nil
+}
+
+// Decode unmarshals a json backup, migrating it to the current schema version.
+func Decode(data []byte) (*InventoryBackup, error) {
+	doc := make(map[string]interface{})
+	err := json.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal backup data: %v", err)
+	}
+
+	original, err := Migrate(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	migrated, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal migrated backup: %v", err)
+	}
+
+	b := &InventoryBackup{}
+	err = json.Unmarshal(migrated, b)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal backup data: %v", err)
+	}
+	b.MigratedFrom = original
+	return b, nil
+}
+
+// Encode marshals a backup at the current schema version.
+func Encode(b *InventoryBackup) ([]byte, error) {
+	b.SchemaVersion = SchemaVersion
+	data, err := json.Marshal(b)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal backup data: %v", err)
+	}
+	return data, nil
+}
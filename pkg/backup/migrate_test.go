@@ -0,0 +1,53 @@
+package backup
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecodeUnversioned(t *testing.T) {
+	b, err := Decode([]byte(`{"BackupDate":"2019-06-01T00:00:00Z","Nodes":[{"InventoryID":"tst-0001"}],"Networks":null,"Systems":null}`))
+	if err != nil {
+		t.Fatalf("unable to decode unversioned backup: %v", err)
+	}
+
+	if b.MigratedFrom != 1 || b.SchemaVersion != SchemaVersion {
+		t.Errorf("expected migration from 1 to %d, got %d to %d", SchemaVersion, b.MigratedFrom, b.SchemaVersion)
+	}
+
+	if len(b.Nodes) != 1 || b.Nodes[0].ID() != "tst-0001" {
+		t.Errorf("nodes weren't preserved by migration: %v", b.Nodes)
+	}
+
+	if b.IPReservations == nil {
+		t.Errorf("expected empty reservation list after migration")
+	}
+}
+
+func TestDecodeNewerVersion(t *testing.T) {
+	_, err := Decode([]byte(`{"SchemaVersion": 1000}`))
+	if err == nil {
+		t.Errorf("expected error decoding backup from a newer schema version")
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	data, err := Encode(&InventoryBackup{})
+	if err != nil {
+		t.Fatalf("unable to encode backup: %v", err)
+	}
+
+	doc := map[string]interface{}{}
+	json.Unmarshal(data, &doc)
+	if doc["SchemaVersion"] != float64(SchemaVersion) {
+		t.Errorf("expected schema version %d, got %v", SchemaVersion, doc["SchemaVersion"])
+	}
+
+	b, err := Decode(data)
+	if err != nil {
+		t.Fatalf("unable to decode backup: %v", err)
+	}
+	if b.MigratedFrom != SchemaVersion {
+		t.Errorf("current backup shouldn't be migrated, got version %d", b.MigratedFrom)
+	}
+}
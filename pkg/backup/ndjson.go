@@ -0,0 +1,273 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+// Format is the encoding of a backup file.
+type Format string
+
+const (
+	// FormatJSON is a single JSON document with a checksum.
+	FormatJSON Format = "json"
+	// FormatNDJSON is a stream of typed records, one per line.
+	FormatNDJSON Format = "ndjson"
+)
+
+// FormatForFile chooses the format of a backup based on the extension of its
+// filename, ignoring any compression extension.
+func FormatForFile(filename string) Format {
+	name := strings.ToLower(filename)
+	if CompressionForFile(name) != CompressionNone {
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+
+	switch filepath.Ext(name) {
+	case ".ndjson", ".jsonl":
+		return FormatNDJSON
+	}
+	return FormatJSON
+}
+
+// Record kinds for the parts of an NDJSON backup that aren't objects.
+const (
+	recordBackup    = "backup"
+	recordTombstone = "tombstone"
+	recordEnd       = "end"
+)
+
+// maxRecordSize limits the length of a single line of an NDJSON backup.
+const maxRecordSize = 64 * 1024 * 1024
+
+// record is a single line of an NDJSON backup.
+type record struct {
+	Kind   string          `json:"kind"`
+	Object json.RawMessage `json:"object"`
+}
+
+// header holds the backup wide fields of an NDJSON backup.
+type header struct {
+	SchemaVersion int
+	BackupDate    time.Time
+	Since         *time.Time `json:",omitempty"`
+}
+
+// trailer ends an NDJSON backup with the number of records before it and a
+// checksum of their lines, so a truncated backup can be detected.
+type trailer struct {
+	Records  int
+	Checksum string
+}
+
+// EncodeNDJSON writes a backup as a header record followed by a record for
+// every object and tombstone and a trailer record.  Objects are written in
+// dependency order.
+func EncodeNDJSON(w io.Writer, b *InventoryBackup) error {
+	sum := sha256.New()
+	enc := json.NewEncoder(io.MultiWriter(w, sum))
+	var records int
+	write := func(kind string, obj interface{}) error {
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return fmt.Errorf("unable to marshal %s: %v", kind, err)
+		}
+		err = enc.Encode(&record{Kind: kind, Object: data})
+		if err != nil {
+			return fmt.Errorf("unable to write %s: %v", kind, err)
+		}
+		records++
+		return nil
+	}
+
+	b.SchemaVersion = SchemaVersion
+	err := write(recordBackup, &header{SchemaVersion: b.SchemaVersion, BackupDate: b.BackupDate, Since: b.Since})
+	if err != nil {
+		return err
+	}
+
+	for _, network := range b.Networks {
+		if err := write(string(KindNetwork), network); err != nil {
+			return err
+		}
+	}
+	for _, system := range b.Systems {
+		if err := write(string(KindSystem), system); err != nil {
+			return err
+		}
+	}
+	for _, node := range b.Nodes {
+		if err := write(string(KindNode), node); err != nil {
+			return err
+		}
+	}
+	for _, reservation := range b.IPReservations {
+		if err := write(string(KindIPReservation), reservation); err != nil {
+			return err
+		}
+	}
+	for _, ref := range b.Tombstones {
+		if err := write(recordTombstone, ref); err != nil {
+			return err
+		}
+	}
+
+	end, err := json.Marshal(&trailer{Records: records, Checksum: checksumPrefix + hex.EncodeToString(sum.Sum(nil))})
+	if err != nil {
+		return fmt.Errorf("unable to marshal %s: %v", recordEnd, err)
+	}
+	err = json.NewEncoder(w).Encode(&record{Kind: recordEnd, Object: end})
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %v", recordEnd, err)
+	}
+	return nil
+}
+
+// DecodeNDJSON reads a backup from a stream of records.  A trailer must match
+// the records before it, back to the previous trailer, so concatenated backups
+// are each checked.  Records without a trailer, such as those grepped or split
+// from a backup, are accepted and counted in Unverified.  Records may appear
+// in any order and the header is optional.  The backup date
+// of concatenated backups is the newest date of their headers, and the backup
+// is migrated from the oldest schema version of their headers.
+func DecodeNDJSON(r io.Reader) (*InventoryBackup, error) {
+	b := &InventoryBackup{
+		SchemaVersion:  SchemaVersion,
+		Nodes:          []*types.Node{},
+		Systems:        []*types.System{},
+		Networks:       []*types.Network{},
+		IPReservations: types.IPReservationList{},
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxRecordSize)
+	sum := sha256.New()
+	version := SchemaVersion
+	var headers, records int
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		rec := &record{}
+		if err := json.Unmarshal(line, rec); err != nil {
+			return nil, fmt.Errorf("unable to read record %d: %v", n, err)
+		}
+		if rec.Object == nil {
+			return nil, fmt.Errorf("record %d has no object", n)
+		}
+
+		if rec.Kind == recordEnd {
+			end := &trailer{}
+			if err := json.Unmarshal(rec.Object, end); err != nil {
+				return nil, fmt.Errorf("unable to unmarshal record %d: %v", n, err)
+			}
+			if end.Records != records {
+				return nil, fmt.Errorf("record %d expects %d records, found %d, backup data is incomplete", n, end.Records, records)
+			}
+			if end.Checksum != checksumPrefix+hex.EncodeToString(sum.Sum(nil)) {
+				return nil, fmt.Errorf("checksum mismatch at record %d, backup data is corrupt", n)
+			}
+			sum.Reset()
+			records = 0
+			continue
+		}
+		sum.Write(line)
+		sum.Write([]byte("\n"))
+		records++
+
+		var obj interface{}
+		switch rec.Kind {
+		case recordBackup:
+			h := &header{}
+			if err := json.Unmarshal(rec.Object, h); err != nil {
+				return nil, fmt.Errorf("unable to unmarshal record %d: %v", n, err)
+			}
+			if h.SchemaVersion > SchemaVersion {
+				return nil, fmt.Errorf("backup schema version %d is newer than the supported version %d", h.SchemaVersion, SchemaVersion)
+			}
+			if h.SchemaVersion < version {
+				version = h.SchemaVersion
+			}
+			if headers == 0 {
+				b.Since = h.Since
+			}
+			if h.BackupDate.After(b.BackupDate) {
+				b.BackupDate = h.BackupDate
+			}
+			headers++
+			continue
+		case string(KindNode):
+			node := &types.Node{}
+			b.Nodes = append(b.Nodes, node)
+			obj = node
+		case string(KindSystem):
+			system := &types.System{}
+			b.Systems = append(b.Systems, system)
+			obj = system
+		case string(KindNetwork):
+			network := &types.Network{}
+			b.Networks = append(b.Networks, network)
+			obj = network
+		case string(KindIPReservation):
+			reservation := &types.IPReservation{}
+			b.IPReservations = append(b.IPReservations, reservation)
+			obj = reservation
+		case recordTombstone:
+			b.Tombstones = append(b.Tombstones, ObjectRef{})
+			obj = &b.Tombstones[len(b.Tombstones)-1]
+		default:
+			return nil, fmt.Errorf("record %d has unknown kind '%s'", n, rec.Kind)
+		}
+
+		if err := json.Unmarshal(rec.Object, obj); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal record %d: %v", n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read records: %v", err)
+	}
+
+	if version < SchemaVersion {
+		migrated, err := migrateBackup(b, version)
+		if err != nil {
+			return nil, err
+		}
+		migrated.Unverified = records
+		return migrated, nil
+	}
+	b.MigratedFrom = version
+	b.Unverified = records
+	return b, nil
+}
+
+// migrateBackup runs the migrations from version on a decoded backup.
+func migrateBackup(b *InventoryBackup, version int) (*InventoryBackup, error) {
+	b.SchemaVersion = version
+	data, err := json.Marshal(b)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal backup for migration: %v", err)
+	}
+	return Decode(data)
+}
+
+// isNDJSON returns true if the first JSON value in data is a record.
+func isNDJSON(data []byte) bool {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return false
+	}
+	t, err := dec.Token()
+	return err == nil && (t == "kind" || t == "object")
+}
@@ -0,0 +1,178 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+func TestNDJSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	date := time.Date(2019, 3, 10, 0, 0, 0, 0, time.UTC)
+	b := &InventoryBackup{
+		BackupDate:     date,
+		Nodes:          []*types.Node{{InventoryID: "tst-0001"}, {InventoryID: "tst-0002"}},
+		Systems:        []*types.System{{Name: "tst"}},
+		IPReservations: types.IPReservationList{testReservation(t, "10.0.0.1/24", "00:11:22:33:44:55")},
+		Tombstones:     []ObjectRef{{KindNode, "tst-0003"}},
+	}
+
+	for _, name := range []string{"backup.ndjson", "backup.jsonl.gz"} {
+		if FormatForFile(name) != FormatNDJSON {
+			t.Errorf("expected %s to be ndjson", name)
+		}
+
+		filename := filepath.Join(dir, name)
+		if err = WriteFile(filename, b, nil); err != nil {
+			t.Fatalf("unable to write %s: %v", name, err)
+		}
+
+		restored, err := ReadFile(filename, nil)
+		if err != nil {
+			t.Fatalf("unable to read %s: %v", name, err)
+		}
+		if !restored.BackupDate.Equal(date) || len(restored.Nodes) != 2 || len(restored.Systems) != 1 ||
+			len(restored.IPReservations) != 1 || len(restored.Tombstones) != 1 {
+			t.Errorf("%s: unexpected backup %+v", name, restored)
+		}
+	}
+
+	data, _ := ioutil.ReadFile(filepath.Join(dir, "backup.ndjson"))
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 7 || !strings.HasPrefix(lines[0], `{"kind":"backup"`) || !strings.HasPrefix(lines[2], `{"kind":"node","object":{"InventoryID":"tst-0001"`) {
+		t.Errorf("unexpected records:\n%s", data)
+	}
+
+	// concatenated backups each keep their own trailer
+	later := &InventoryBackup{BackupDate: date.Add(time.Hour), Networks: []*types.Network{{Name: "prod"}}}
+	buf := &bytes.Buffer{}
+	if err = EncodeNDJSON(buf, later); err != nil {
+		t.Fatalf("unable to encode backup: %v", err)
+	}
+	combined := append(append([]byte{}, data...), buf.Bytes()...)
+	restored, err := Unmarshal(combined, nil)
+	if err != nil {
+		t.Fatalf("unable to read concatenated backups: %v", err)
+	}
+	if !restored.BackupDate.Equal(later.BackupDate) || len(restored.Nodes) != 2 || len(restored.Networks) != 1 {
+		t.Errorf("unexpected concatenated backup %+v", restored)
+	}
+
+	// records that don't match the trailer that follows them are rejected
+	for _, partial := range [][]string{lines[1:], append(lines[:6:6], strings.TrimSpace(buf.String()))} {
+		if _, err = Unmarshal([]byte(strings.Join(partial, "\n")), nil); err == nil {
+			t.Errorf("expected an error reading a partial backup:\n%s", strings.Join(partial, "\n"))
+		}
+	}
+	if _, err = Unmarshal(append(append([]byte{}, data...), buf.Bytes()[:buf.Len()/2]...), nil); err == nil {
+		t.Errorf("expected an error reading a truncated concatenated backup")
+	}
+
+	if _, err = Unmarshal([]byte(`{"kind":"rack","object":{}}`), nil); err == nil {
+		t.Errorf("expected an error for an unknown record kind")
+	}
+}
+
+func TestNDJSONSubset(t *testing.T) {
+	b := &InventoryBackup{
+		Nodes:   []*types.Node{{InventoryID: "tst-0001", System: "tst"}, {InventoryID: "tst-0002", System: "tst"}, {InventoryID: "hpc-0001", System: "hpc"}},
+		Systems: []*types.System{{Name: "tst"}, {Name: "hpc"}},
+	}
+	buf := &bytes.Buffer{}
+	if err := EncodeNDJSON(buf, b); err != nil {
+		t.Fatalf("unable to encode backup: %v", err)
+	}
+
+	restored, err := Unmarshal(buf.Bytes(), nil)
+	if err != nil || restored.Unverified != 0 {
+		t.Errorf("expected a complete backup to be verified: %v", err)
+	}
+
+	// the equivalent of grep '"System":"tst"'
+	grepped := []string{}
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.Contains(line, `"System":"tst"`) {
+			grepped = append(grepped, line)
+		}
+	}
+	restored, err = Unmarshal([]byte(strings.Join(grepped, "\n")), nil)
+	if err != nil {
+		t.Fatalf("unable to read grepped records: %v", err)
+	}
+	if len(restored.Nodes) != 2 || len(restored.Systems) != 0 || restored.Unverified != 2 {
+		t.Errorf("unexpected backup read from grepped records: %+v", restored)
+	}
+
+	// the first half of a split backup
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	restored, err = Unmarshal([]byte(strings.Join(lines[:4], "\n")), nil)
+	if err != nil || restored.Unverified != 4 || len(restored.Systems) != 2 || len(restored.Nodes) != 1 {
+		t.Errorf("unexpected result reading the start of a split backup: %v", err)
+	}
+}
+
+// ndjsonSegment builds an NDJSON backup from records, ending it with a trailer.
+func ndjsonSegment(records ...string) []byte {
+	data := []byte(strings.Join(records, "\n") + "\n")
+	sum := sha256.Sum256(data)
+	end := fmt.Sprintf(`{"kind":"end","object":{"Records":%d,"Checksum":"sha256:%s"}}`, len(records), hex.EncodeToString(sum[:]))
+	return append(data, end+"\n"...)
+}
+
+func TestNDJSONMigration(t *testing.T) {
+	data := ndjsonSegment(
+		`{"kind":"backup","object":{"SchemaVersion":1,"BackupDate":"2019-03-10T00:00:00Z"}}`,
+		`{"kind":"node","object":{"InventoryID":"tst-0001"}}`,
+	)
+	restored, err := Unmarshal(data, nil)
+	if err != nil {
+		t.Fatalf("unable to read version 1 backup: %v", err)
+	}
+	if restored.MigratedFrom != 1 || restored.SchemaVersion != SchemaVersion || len(restored.Nodes) != 1 || restored.IPReservations == nil {
+		t.Errorf("backup wasn't migrated: %+v", restored)
+	}
+
+	current, err := Unmarshal(ndjsonSegment(`{"kind":"node","object":{"InventoryID":"tst-0001"}}`), nil)
+	if err != nil || current.MigratedFrom != SchemaVersion {
+		t.Errorf("unexpected result reading a backup without a header: %v", err)
+	}
+}
+
+func TestNDJSONEncryptedStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "backup.ndjson.zst")
+	keys := &Keys{Passphrase: func() (string, error) { return "correct horse", nil }}
+	err = WriteFile(filename, &InventoryBackup{Nodes: []*types.Node{{InventoryID: "tst-0001"}}}, keys)
+	if err != nil {
+		t.Fatalf("unable to write backup: %v", err)
+	}
+
+	data, _ := ioutil.ReadFile(filename)
+	if !IsEncrypted(data) {
+		t.Errorf("backup wasn't encrypted")
+	}
+
+	restored, err := ReadFile(filename, keys)
+	if err != nil || len(restored.Nodes) != 1 {
+		t.Errorf("unable to read encrypted backup: %v", err)
+	}
+}
@@ -0,0 +1,335 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+// Action is the operation an import will perform for a single object.
+type Action string
+
+const (
+	ActionCreate        Action = "create"
+	ActionUpdate        Action = "update"
+	ActionSkipOlder     Action = "skip-older"
+	ActionSkipIdentical Action = "skip-identical"
+	ActionSkipExisting  Action = "skip-existing"
+	ActionSkipExpired   Action = "skip-expired"
+	ActionDelete        Action = "delete"
+	ActionConflict      Action = "conflict"
+	ActionBlocked       Action = "blocked"
+	ActionRedacted      Action = "redacted"
+)
+
+// actions lists every action in the order they are summarized.
+var actions = []Action{ActionCreate, ActionUpdate, ActionDelete, ActionConflict, ActionSkipOlder, ActionSkipIdentical, ActionSkipExisting, ActionSkipExpired, ActionBlocked, ActionRedacted}
+
+// ConflictPolicy decides what happens when an object in a backup differs from
+// the live version of the same object.
+type ConflictPolicy string
+
+const (
+	// NewestWins updates objects whose live version is older than the backup.
+	NewestWins ConflictPolicy = "newest-wins"
+	// BackupWins overwrites every live object that differs from the backup.
+	BackupWins ConflictPolicy = "backup-wins"
+	// LiveWins only creates objects that don't exist yet.
+	LiveWins ConflictPolicy = "live-wins"
+	// Interactive marks every differing object as a conflict to be resolved by the user.
+	Interactive ConflictPolicy = "interactive"
+)
+
+// ParseConflictPolicy validates a conflict policy name.
+func ParseConflictPolicy(name string) (ConflictPolicy, error) {
+	switch policy := ConflictPolicy(name); policy {
+	case NewestWins, BackupWins, LiveWins, Interactive:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown conflict policy '%s', must be one of %s, %s, %s or %s", name, NewestWins, BackupWins, LiveWins, Interactive)
+}
+
+// PlanOptions controls how a plan is built.
+type PlanOptions struct {
+	// OnConflict defaults to NewestWins
+	OnConflict ConflictPolicy
+	// Prune lists the kinds of live objects that are deleted if they are
+	// missing from the backup.
+	Prune []Kind
+	// PruneScope restricts the live objects that may be deleted.
+	PruneScope Filter
+	// Redaction is the policy used to redact the backup.  With an hmac key
+	// it tells whether redacted values differ from the live values.
+	Redaction *RedactionPolicy
+}
+
+// PlanItem describes what an import will do with a single object from a backup.
+type PlanItem struct {
+	Kind    Kind          `json:"kind"`
+	ID      string        `json:"id"`
+	Action  Action        `json:"action"`
+	Reason  string        `json:"reason,omitempty"`
+	Changes []FieldChange `json:"changes,omitempty"`
+	Object  Object        `json:"-"`
+}
+
+// Plan is the ordered list of actions required to restore a backup.
+type Plan struct {
+	Items []*PlanItem `json:"items"`
+}
+
+// NewPlan compares the objects in a backup against the live state of the
+// inventory and determines the action to take for each of them.  Items are
+// ordered so that every object is written after the objects it references.
+// Nodes that reference systems, roles, environments or networks that exist in
+// neither the backup nor the live inventory are blocked, as are ip reservations
+// that collide with a live reservation or don't belong to any known network.
+// Objects holding values removed by a redaction policy are blocked too.
+//
+// When pruning, live objects missing from the backup are deleted after all
+// other objects have been written.  Deleting a system or network that remains
+// in use is blocked.
+func NewPlan(backupData, live *InventoryBackup, opts PlanOptions) (*Plan, error) {
+	if opts.OnConflict == "" {
+		opts.OnConflict = NewestWins
+	}
+
+	now := time.Now()
+	items := make(map[ObjectRef]*PlanItem)
+	for _, kind := range Kinds {
+		liveObjects := live.Objects(kind)
+		for id, obj := range backupData.Objects(kind) {
+			item, err := planRedactedObject(kind, obj, liveObjects[id], opts, now)
+			if err != nil {
+				return nil, err
+			}
+			items[ObjectRef{kind, id}] = item
+		}
+	}
+
+	deletes := planDeletes(backupData, opts.PruneScope.Apply(live), opts.Prune)
+	for _, item := range deletes {
+		items[ObjectRef{item.Kind, item.ID}] = item
+	}
+	// blocking a delete keeps the objects it references in use, so check until
+	// no more deletes are blocked
+	for blocked := -1; blocked != 0; {
+		blocked = checkDeleteReferences(deletes, resultingObjects(KindNode, live, items), resultingObjects(KindSystem, live, items))
+	}
+
+	systems := resultingObjects(KindSystem, live, items)
+	networks := resultingObjects(KindNetwork, live, items)
+	for _, node := range backupData.Nodes {
+		item := items[ObjectRef{KindNode, node.ID()}]
+		if !item.writes() && item.Action != ActionConflict {
+			continue
+		}
+		if err := checkNodeReferences(node, systems, networks); err != nil {
+			item.Action = ActionBlocked
+			item.Reason = err.Error()
+		}
+	}
+
+	for _, reservation := range backupData.IPReservations {
+		item := items[ObjectRef{KindIPReservation, Reservation{reservation}.ID()}]
+		if !item.writes() && item.Action != ActionConflict {
+			continue
+		}
+		if err := checkReservationReferences(Reservation{reservation}, networks); err != nil {
+			item.Action = ActionBlocked
+			item.Reason = err.Error()
+		}
+	}
+
+	order, err := NewDependencyGraph(backupData).Sort()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{Items: make([]*PlanItem, 0, len(order)+len(deletes))}
+	for _, ref := range order {
+		plan.Items = append(plan.Items, items[ref])
+	}
+	plan.Items = append(plan.Items, deletes...)
+	return plan, nil
+}
+
+// resultingObjects returns the objects of a kind as they will exist once the
+// planned items have been applied.
+func resultingObjects(kind Kind, live *InventoryBackup, items map[ObjectRef]*PlanItem) map[string]Object {
+	objects := live.Objects(kind)
+	for ref, item := range items {
+		if ref.Kind != kind {
+			continue
+		}
+		if item.writes() {
+			objects[ref.ID] = item.Object
+		} else if item.Action == ActionDelete {
+			delete(objects, ref.ID)
+		}
+	}
+	return objects
+}
+
+// planRedactedObject plans an object after restoring its redacted values from
+// the live object.  Objects with redacted values that don't exist in the live
+// inventory can't be written.
+func planRedactedObject(kind Kind, obj, liveObj Object, opts PlanOptions, now time.Time) (*PlanItem, error) {
+	restored, missing, differs, err := restoreRedacted(obj, liveObj, opts.Redaction)
+	if err != nil {
+		return nil, fmt.Errorf("unable to restore redacted values of %s %s: %v", kind, obj.ID(), err)
+	}
+	if len(missing) > 0 {
+		return &PlanItem{Kind: kind, ID: obj.ID(), Action: ActionRedacted, Object: obj,
+			Reason: fmt.Sprintf("no live value to keep for redacted %s", strings.Join(missing, ", "))}, nil
+	}
+
+	var item *PlanItem
+	if reservation, ok := restored.(Reservation); ok {
+		item, err = planReservation(reservation, liveObj, opts.OnConflict, now)
+	} else {
+		item, err = planObject(kind, restored, liveObj, opts.OnConflict)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(differs) > 0 && item.Reason == "" {
+		item.Reason = fmt.Sprintf("redacted %s differs from the live value, keeping the live value", strings.Join(differs, ", "))
+	}
+	return item, nil
+}
+
+func checkNodeReferences(node *types.Node, systems, networks map[string]Object) error {
+	if errs := nodeReferenceErrors(node, systems, networks); len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// nodeReferenceErrors returns an error for every reference from a node to a
+// system, role, environment or network that doesn't exist.
+func nodeReferenceErrors(node *types.Node, systems, networks map[string]Object) []error {
+	errs := []error{}
+	if node.System != "" {
+		obj, ok := systems[node.System]
+		if !ok {
+			errs = append(errs, fmt.Errorf("system %s doesn't exist", node.System))
+		} else {
+			system := obj.(*types.System)
+
+			if node.Role != "" && !containsString(system.Roles, node.Role) {
+				errs = append(errs, fmt.Errorf("role %s isn't defined for system %s", node.Role, node.System))
+			}
+
+			if _, ok := system.Environments[node.Environment]; node.Environment != "" && !ok {
+				errs = append(errs, fmt.Errorf("environment %s isn't defined for system %s", node.Environment, node.System))
+			}
+		}
+	}
+
+	for _, network := range sortedKeys(node.Networks) {
+		if _, ok := networks[network]; !ok {
+			errs = append(errs, fmt.Errorf("network %s doesn't exist", network))
+		}
+	}
+	return errs
+}
+
+func sortedKeys(networks types.NICInfoMap) []string {
+	keys := make([]string, 0, len(networks))
+	for key := range networks {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func planObject(kind Kind, obj, liveObj Object, policy ConflictPolicy) (*PlanItem, error) {
+	item := &PlanItem{Kind: kind, ID: obj.ID(), Object: obj}
+	if liveObj == nil {
+		item.Action = ActionCreate
+		return item, nil
+	}
+
+	changes, err := Diff(liveObj, obj)
+	if err != nil {
+		return nil, fmt.Errorf("unable to compare %s %s: %v", kind, obj.ID(), err)
+	}
+
+	if len(changes) == 0 {
+		item.Action = ActionSkipIdentical
+		return item, nil
+	}
+	item.Changes = changes
+
+	switch {
+	case policy == BackupWins:
+		item.Action = ActionUpdate
+	case policy == LiveWins:
+		item.Action = ActionSkipExisting
+	case policy == Interactive:
+		item.Action = ActionConflict
+		item.Reason = "live object differs from backup"
+	case liveObj.Timestamp() < obj.Timestamp():
+		item.Action = ActionUpdate
+	case liveObj.Timestamp() == obj.Timestamp():
+		item.Action = ActionConflict
+		item.Reason = "timestamps are equal but content differs"
+	default:
+		item.Action = ActionSkipOlder
+		item.Changes = nil
+	}
+	return item, nil
+}
+
+// writes returns true if the item will be written to the inventory.
+func (i *PlanItem) writes() bool {
+	return i.Action == ActionCreate || i.Action == ActionUpdate
+}
+
+// Counts returns the number of plan items for each action.
+func (p *Plan) Counts() map[Action]int {
+	counts := make(map[Action]int)
+	for _, item := range p.Items {
+		counts[item.Action]++
+	}
+	return counts
+}
+
+// Print writes a human readable version of the plan to w.
+func (p *Plan) Print(w io.Writer) {
+	for _, item := range p.Items {
+		fmt.Fprintf(w, "%-15s %-8s %s\n", item.Action, item.Kind, item.ID)
+		if item.Reason != "" {
+			fmt.Fprintf(w, "    %s\n", item.Reason)
+		}
+		for _, change := range item.Changes {
+			fmt.Fprintf(w, "    %s\n", change)
+		}
+	}
+
+	counts := p.Counts()
+	summary := []string{}
+	for _, action := range actions {
+		if counts[action] > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", counts[action], action))
+		}
+	}
+	if len(summary) == 0 {
+		summary = append(summary, "nothing to do")
+	}
+	fmt.Fprintf(w, "Plan: %s.\n", strings.Join(summary, ", "))
+}
@@ -0,0 +1,157 @@
+package backup
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+func TestNewPlan(t *testing.T) {
+	older := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+
+	backupData := &InventoryBackup{
+		Nodes: []*types.Node{
+			{InventoryID: "tst-0001", Role: "compute", LastUpdated: newer},
+			{InventoryID: "tst-0002", Role: "compute", LastUpdated: newer},
+			{InventoryID: "tst-0003", Role: "compute", LastUpdated: older},
+			{InventoryID: "tst-0004", Role: "compute", LastUpdated: older},
+		},
+	}
+	live := &InventoryBackup{
+		Nodes: []*types.Node{
+			{InventoryID: "tst-0002", Role: "storage", LastUpdated: older},
+			{InventoryID: "tst-0003", Role: "storage", LastUpdated: newer},
+			{InventoryID: "tst-0004", Role: "compute", LastUpdated: older},
+		},
+	}
+
+	plan, err := NewPlan(backupData, live, PlanOptions{})
+	if err != nil {
+		t.Fatalf("unable to build plan: %v", err)
+	}
+
+	expected := map[string]Action{
+		"tst-0001": ActionCreate,
+		"tst-0002": ActionUpdate,
+		"tst-0003": ActionSkipOlder,
+		"tst-0004": ActionSkipIdentical,
+	}
+	if len(plan.Items) != len(expected) {
+		t.Fatalf("expected %d plan items, got %d", len(expected), len(plan.Items))
+	}
+	for _, item := range plan.Items {
+		if item.Action != expected[item.ID] {
+			t.Errorf("expected %s for %s, got %s", expected[item.ID], item.ID, item.Action)
+		}
+	}
+
+	update := plan.Items[1]
+	if len(update.Changes) != 2 || update.Changes[1].Field != "Role" || update.Changes[1].New != "compute" {
+		t.Errorf("unexpected changes for update: %v", update.Changes)
+	}
+}
+
+func TestDiffIgnoresEmptyValues(t *testing.T) {
+	changes, err := Diff(&types.Node{InventoryID: "tst-0001"}, &types.Node{InventoryID: "tst-0001", Metadata: types.Metadata{}})
+	if err != nil {
+		t.Fatalf("unable to diff nodes: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("expected no changes, got %v", changes)
+	}
+}
+
+func TestNewPlanDependencies(t *testing.T) {
+	backupData := &InventoryBackup{
+		Nodes: []*types.Node{
+			{InventoryID: "tst-0001", System: "tst", Role: "compute", Environment: "prod", Networks: types.NICInfoMap{"prod": &types.NetworkInterface{}}},
+			{InventoryID: "tst-0002", System: "tst", Role: "missing"},
+			{InventoryID: "tst-0003", System: "other"},
+			{InventoryID: "tst-0004", System: "live", Networks: types.NICInfoMap{"missing": &types.NetworkInterface{}}},
+		},
+		Systems: []*types.System{
+			{Name: "tst", Roles: []string{"compute"}, Environments: map[string]*types.Environment{"prod": {Networks: map[string]string{"provisioning": "prod"}}}},
+		},
+		Networks: []*types.Network{{Name: "prod"}},
+	}
+	live := &InventoryBackup{Systems: []*types.System{{Name: "live"}}}
+
+	plan, err := NewPlan(backupData, live, PlanOptions{})
+	if err != nil {
+		t.Fatalf("unable to build plan: %v", err)
+	}
+
+	order := []string{}
+	for _, item := range plan.Items {
+		order = append(order, item.ID)
+	}
+	expectedOrder := []string{"prod", "tst", "tst-0001", "tst-0002", "tst-0003", "tst-0004"}
+	if strings.Join(order, ",") != strings.Join(expectedOrder, ",") {
+		t.Errorf("unexpected plan order: %v", order)
+	}
+
+	expected := map[string]Action{
+		"tst-0001": ActionCreate,
+		"tst-0002": ActionBlocked,
+		"tst-0003": ActionBlocked,
+		"tst-0004": ActionBlocked,
+	}
+	for _, item := range plan.Items[2:] {
+		if item.Action != expected[item.ID] {
+			t.Errorf("expected %s for %s, got %s (%s)", expected[item.ID], item.ID, item.Action, item.Reason)
+		}
+	}
+}
+
+func TestDependencyGraphCycle(t *testing.T) {
+	g := &DependencyGraph{dependencies: make(map[ObjectRef]map[ObjectRef]bool)}
+	a, b := ObjectRef{KindNode, "a"}, ObjectRef{KindNode, "b"}
+	g.add(a)
+	g.add(b)
+	g.addDependency(a, b)
+	g.addDependency(b, a)
+	if _, err := g.Sort(); err == nil {
+		t.Errorf("expected error sorting graph with a cycle")
+	}
+}
+
+func TestNewPlanConflictPolicies(t *testing.T) {
+	ts := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	backupData := &InventoryBackup{
+		Nodes: []*types.Node{
+			{InventoryID: "tst-0001", Role: "compute", LastUpdated: ts},
+			{InventoryID: "tst-0002", Role: "compute", LastUpdated: ts.Add(-time.Hour)},
+		},
+	}
+	live := &InventoryBackup{
+		Nodes: []*types.Node{
+			{InventoryID: "tst-0001", Role: "storage", LastUpdated: ts},
+			{InventoryID: "tst-0002", Role: "storage", LastUpdated: ts},
+		},
+	}
+
+	cases := map[ConflictPolicy][]Action{
+		NewestWins:  {ActionConflict, ActionSkipOlder},
+		BackupWins:  {ActionUpdate, ActionUpdate},
+		LiveWins:    {ActionSkipExisting, ActionSkipExisting},
+		Interactive: {ActionConflict, ActionConflict},
+	}
+	for policy, expected := range cases {
+		plan, err := NewPlan(backupData, live, PlanOptions{OnConflict: policy})
+		if err != nil {
+			t.Fatalf("unable to build plan: %v", err)
+		}
+		for i, item := range plan.Items {
+			if item.Action != expected[i] {
+				t.Errorf("%s: expected %s for %s, got %s", policy, expected[i], item.ID, item.Action)
+			}
+		}
+	}
+
+	if _, err := ParseConflictPolicy("oldest-wins"); err == nil {
+		t.Errorf("expected error parsing invalid conflict policy")
+	}
+}
@@ -0,0 +1,148 @@
+package backup
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// Pool runs work on a bounded number of goroutines, optionally limiting the
+// rate at which work is started.
+type Pool struct {
+	// Concurrency is the number of workers, at least one worker is used.
+	Concurrency int
+	// Limiter limits the rate work is started at if set.
+	Limiter *rate.Limiter
+	// StopOnError stops starting new work once a call has failed.
+	StopOnError bool
+}
+
+// NewPool returns a pool with the specified number of workers, starting at
+// most perSecond calls per second.  A rate of zero is unlimited.
+func NewPool(concurrency int, perSecond float64) *Pool {
+	pool := &Pool{Concurrency: concurrency}
+	if perSecond > 0 {
+		pool.Limiter = rate.NewLimiter(rate.Limit(perSecond), 1)
+	}
+	return pool
+}
+
+// Run calls fn for every index from 0 to n-1.  The errors are returned in
+// index order, ran records which indexes were called.
+func (p *Pool) Run(n int, fn func(i int) error) (errs []error, ran []bool) {
+	errs = make([]error, n)
+	ran = make([]bool, n)
+
+	workers := p.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+
+	indexes := make(chan int)
+	var stopped bool
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				mu.Lock()
+				skip := stopped
+				mu.Unlock()
+				if skip {
+					continue
+				}
+
+				if p.Limiter != nil {
+					p.Limiter.Wait(context.Background())
+				}
+				err := fn(i)
+
+				mu.Lock()
+				errs[i] = err
+				ran[i] = true
+				if err != nil && p.StopOnError {
+					stopped = true
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	return errs, ran
+}
+
+// Stages splits the plan into runs of consecutive items of the same kind that
+// are either all deleted or all written.  Items within a stage don't depend on
+// each other, so they can be applied concurrently as long as the stages are
+// applied in order.
+func (p *Plan) Stages() [][]*PlanItem {
+	stages := [][]*PlanItem{}
+	for i, item := range p.Items {
+		if i == 0 || item.Kind != p.Items[i-1].Kind || (item.Action == ActionDelete) != (p.Items[i-1].Action == ActionDelete) {
+			stages = append(stages, []*PlanItem{})
+		}
+		stages[len(stages)-1] = append(stages[len(stages)-1], item)
+	}
+	return stages
+}
+
+// Apply calls apply for every item in the plan that creates, updates or
+// deletes an object, one stage at a time, and adds the results to the report
+// in plan order.  Only those items make api requests, so the other items are
+// reported without being run or waiting for the limiter.  If the pool stops on
+// errors, the result of the first failed item is returned once the items
+// already started have finished.
+func (p *Pool) Apply(plan *Plan, apply func(item *PlanItem) error, report *Report) *Result {
+	for _, stage := range plan.Stages() {
+		changes := []*PlanItem{}
+		for _, item := range stage {
+			if changesObject(item) {
+				changes = append(changes, item)
+			}
+		}
+		errs, ran := p.Run(len(changes), func(i int) error {
+			return apply(changes[i])
+		})
+
+		var failed *Result
+		var change int
+		for _, item := range stage {
+			if !changesObject(item) {
+				report.Add(item, nil)
+				continue
+			}
+			i := change
+			change++
+			if !ran[i] {
+				continue
+			}
+			result := report.Add(item, errs[i])
+			if errs[i] != nil && failed == nil {
+				failed = result
+			}
+		}
+		if failed != nil && p.StopOnError {
+			return failed
+		}
+	}
+	return nil
+}
+
+func changesObject(item *PlanItem) bool {
+	switch item.Action {
+	case ActionCreate, ActionUpdate, ActionDelete:
+		return true
+	}
+	return false
+}
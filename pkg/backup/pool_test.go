@@ -0,0 +1,92 @@
+package backup
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestPoolApply(t *testing.T) {
+	plan := &Plan{Items: []*PlanItem{
+		{Kind: KindNetwork, ID: "prod", Action: ActionCreate},
+		{Kind: KindNetwork, ID: "mgmt", Action: ActionSkipIdentical},
+		{Kind: KindSystem, ID: "tst", Action: ActionUpdate},
+	}}
+	for i := 0; i < 20; i++ {
+		plan.Items = append(plan.Items, &PlanItem{Kind: KindNode, ID: fmt.Sprintf("tst-%04d", i), Action: ActionCreate})
+	}
+	plan.Items = append(plan.Items, &PlanItem{Kind: KindIPReservation, ID: "10.0.0.1/24", Action: ActionCreate})
+
+	if stages := plan.Stages(); len(stages) != 4 || len(stages[2]) != 20 {
+		t.Fatalf("unexpected stages: %v", stages)
+	}
+
+	var mu sync.Mutex
+	applied := []Kind{}
+	apply := func(item *PlanItem) error {
+		mu.Lock()
+		defer mu.Unlock()
+		applied = append(applied, item.Kind)
+		if item.ID == "tst-0005" {
+			return fmt.Errorf("boom")
+		}
+		return nil
+	}
+
+	report := NewReport()
+	if failed := NewPool(4, 0).Apply(plan, apply, report); failed != nil {
+		t.Fatalf("expected all items to be applied, failed on %s", failed.ID)
+	}
+	if len(report.Results) != len(plan.Items) || report.Failed() != 1 {
+		t.Fatalf("expected %d results with 1 failure, got %d with %d", len(plan.Items), len(report.Results), report.Failed())
+	}
+	for i, result := range report.Results {
+		if result.ID != plan.Items[i].ID {
+			t.Errorf("expected result %d for %s, got %s", i, plan.Items[i].ID, result.ID)
+		}
+	}
+	for i := 1; i < len(applied); i++ {
+		if kindOrder[applied[i]] < kindOrder[applied[i-1]] {
+			t.Errorf("%s applied after %s", applied[i], applied[i-1])
+		}
+	}
+
+	pool := NewPool(4, 0)
+	pool.StopOnError = true
+	applied = []Kind{}
+	report = NewReport()
+	failed := pool.Apply(plan, apply, report)
+	if failed == nil || failed.ID != "tst-0005" {
+		t.Fatalf("expected import to stop at tst-0005, got %v", failed)
+	}
+	for _, kind := range applied {
+		if kind == KindIPReservation {
+			t.Errorf("reservation was applied after a node failed")
+		}
+	}
+}
+
+func TestPoolApplyRate(t *testing.T) {
+	plan := &Plan{Items: []*PlanItem{{Kind: KindNode, ID: "tst-0000", Action: ActionCreate}}}
+	for i := 1; i <= 100; i++ {
+		plan.Items = append(plan.Items, &PlanItem{Kind: KindNode, ID: fmt.Sprintf("tst-%04d", i), Action: ActionSkipIdentical})
+	}
+	plan.Items = append(plan.Items, &PlanItem{Kind: KindNode, ID: "tst-0101", Action: ActionUpdate})
+
+	// skipped items taking a token would take at least 5 seconds
+	pool := &Pool{Concurrency: 1, Limiter: rate.NewLimiter(rate.Every(50*time.Millisecond), 1)}
+	report := NewReport()
+	start := time.Now()
+	if failed := pool.Apply(plan, func(*PlanItem) error { return nil }, report); failed != nil {
+		t.Fatalf("unexpected failure applying %s", failed.ID)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected only the create and update to wait for the limiter, took %s", elapsed)
+	}
+	if len(report.Results) != len(plan.Items) || report.Results[1].ID != "tst-0001" {
+		t.Errorf("expected %d results in plan order, got %d", len(plan.Items), len(report.Results))
+	}
+}
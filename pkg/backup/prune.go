@@ -0,0 +1,105 @@
+package backup
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+// planDeletes returns delete items for the live objects of the pruned kinds
+// that are missing from the backup.  An incremental backup only contains the
+// objects that changed, so only objects it has tombstones for are deleted.
+// Items are ordered so that objects are deleted before the objects they
+// reference.
+func planDeletes(backupData, live *InventoryBackup, kinds []Kind) []*PlanItem {
+	tombstones := make(map[ObjectRef]bool)
+	for _, ref := range backupData.Tombstones {
+		tombstones[ref] = true
+	}
+
+	items := []*PlanItem{}
+	for i := len(Kinds) - 1; i >= 0; i-- {
+		kind := Kinds[i]
+		if !containsKind(kinds, kind) {
+			continue
+		}
+
+		backupObjects := backupData.Objects(kind)
+		liveObjects := live.Objects(kind)
+		for _, id := range sortedIDs(liveObjects) {
+			ref := ObjectRef{kind, id}
+			if _, ok := backupObjects[id]; ok {
+				continue
+			}
+
+			item := &PlanItem{Kind: kind, ID: id, Action: ActionDelete, Reason: "missing from backup", Object: liveObjects[id]}
+			if tombstones[ref] {
+				item.Reason = "deleted in backup"
+			} else if backupData.Since != nil {
+				continue
+			}
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// checkDeleteReferences blocks deleting systems and networks that are still
+// referenced by the nodes and systems that will remain after the import, and
+// returns the number of deletes it blocked.
+func checkDeleteReferences(items []*PlanItem, nodes, systems map[string]Object) int {
+	referencedBy := make(map[ObjectRef][]string)
+	for _, id := range sortedIDs(nodes) {
+		node := nodes[id].(*types.Node)
+		if node.System != "" {
+			ref := ObjectRef{KindSystem, node.System}
+			referencedBy[ref] = append(referencedBy[ref], fmt.Sprintf("node %s", id))
+		}
+		for _, network := range sortedKeys(node.Networks) {
+			ref := ObjectRef{KindNetwork, network}
+			referencedBy[ref] = append(referencedBy[ref], fmt.Sprintf("node %s", id))
+		}
+	}
+
+	for _, id := range sortedIDs(systems) {
+		networks := make(map[string]bool)
+		for _, environment := range systems[id].(*types.System).Environments {
+			if environment == nil {
+				continue
+			}
+			for _, network := range environment.Networks {
+				networks[network] = true
+			}
+		}
+		for network := range networks {
+			ref := ObjectRef{KindNetwork, network}
+			referencedBy[ref] = append(referencedBy[ref], fmt.Sprintf("system %s", id))
+		}
+	}
+
+	var blocked int
+	for _, item := range items {
+		users := referencedBy[ObjectRef{item.Kind, item.ID}]
+		if item.Action != ActionDelete || len(users) == 0 {
+			continue
+		}
+		sort.Strings(users)
+		item.Action = ActionBlocked
+		item.Reason = fmt.Sprintf("still referenced by %s", users[0])
+		if len(users) > 1 {
+			item.Reason = fmt.Sprintf("%s and %d others", item.Reason, len(users)-1)
+		}
+		blocked++
+	}
+	return blocked
+}
+
+func containsKind(kinds []Kind, kind Kind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
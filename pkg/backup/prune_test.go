@@ -0,0 +1,130 @@
+package backup
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+func TestNewPlanPrune(t *testing.T) {
+	backupData := &InventoryBackup{
+		Nodes:    []*types.Node{{InventoryID: "tst-0001", System: "kept"}},
+		Systems:  []*types.System{{Name: "tst"}},
+		Networks: []*types.Network{{Name: "prod"}},
+	}
+	live := &InventoryBackup{
+		Nodes: []*types.Node{
+			{InventoryID: "tst-0001", System: "kept"},
+			{InventoryID: "tst-0002", System: "gone"},
+		},
+		Systems: []*types.System{
+			{Name: "tst"},
+			{Name: "gone", Environments: map[string]*types.Environment{"prod": {Networks: map[string]string{"provisioning": "used"}}}},
+			{Name: "kept"},
+		},
+		Networks: []*types.Network{{Name: "prod"}, {Name: "used"}, {Name: "unused"}},
+	}
+
+	plan, err := NewPlan(backupData, live, PlanOptions{Prune: Kinds})
+	if err != nil {
+		t.Fatalf("unable to build plan: %v", err)
+	}
+
+	expected := []struct {
+		ref    ObjectRef
+		action Action
+	}{
+		{ObjectRef{KindNode, "tst-0002"}, ActionDelete},
+		{ObjectRef{KindSystem, "gone"}, ActionDelete},
+		{ObjectRef{KindSystem, "kept"}, ActionBlocked},
+		{ObjectRef{KindNetwork, "unused"}, ActionDelete},
+		{ObjectRef{KindNetwork, "used"}, ActionDelete},
+	}
+	deletes := plan.Items[len(plan.Items)-len(expected):]
+	for i, item := range deletes {
+		if (ObjectRef{item.Kind, item.ID}) != expected[i].ref || item.Action != expected[i].action {
+			t.Errorf("expected %s %s at %d, got %s %s/%s (%s)", expected[i].action, expected[i].ref, i, item.Action, item.Kind, item.ID, item.Reason)
+		}
+	}
+
+	plan, err = NewPlan(backupData, live, PlanOptions{Prune: []Kind{KindSystem, KindNetwork}})
+	if err != nil {
+		t.Fatalf("unable to build plan: %v", err)
+	}
+	for _, item := range plan.Items {
+		if item.Kind == KindNode && item.ID == "tst-0002" {
+			t.Errorf("node was planned although nodes aren't pruned: %s", item.Action)
+		}
+		if item.Kind == KindSystem && item.ID == "gone" && item.Action != ActionBlocked {
+			t.Errorf("expected deleting a system used by a remaining node to be blocked, got %s", item.Action)
+		}
+		if item.Kind == KindNetwork && item.ID == "used" && item.Action != ActionBlocked {
+			t.Errorf("expected deleting a network used by a remaining system to be blocked, got %s", item.Action)
+		}
+	}
+
+	plan, err = NewPlan(backupData, live, PlanOptions{Prune: Kinds, PruneScope: Filter{Kinds: []Kind{KindNetwork}}})
+	if err != nil {
+		t.Fatalf("unable to build plan: %v", err)
+	}
+	if counts := plan.Counts(); counts[ActionDelete] != 1 {
+		t.Errorf("expected only the unused network to be deleted, got %v", counts)
+	}
+}
+
+func TestNewPlanPruneCascade(t *testing.T) {
+	// the kept node blocks deleting its system, which then blocks deleting the
+	// network the system uses, which blocks nothing else
+	backupData := &InventoryBackup{Nodes: []*types.Node{{InventoryID: "tst-0001", System: "tst"}}}
+	live := &InventoryBackup{
+		Nodes: []*types.Node{{InventoryID: "tst-0001", System: "tst"}},
+		Systems: []*types.System{
+			{Name: "tst", Environments: map[string]*types.Environment{"prod": {Networks: map[string]string{"provisioning": "prod"}}}},
+		},
+		Networks: []*types.Network{{Name: "prod"}, {Name: "unused"}},
+	}
+
+	plan, err := NewPlan(backupData, live, PlanOptions{Prune: []Kind{KindSystem, KindNetwork}})
+	if err != nil {
+		t.Fatalf("unable to build plan: %v", err)
+	}
+	reasons := map[ObjectRef]string{}
+	for _, item := range plan.Items {
+		if item.Action == ActionBlocked || item.Action == ActionDelete {
+			reasons[ObjectRef{item.Kind, item.ID}] = string(item.Action) + ": " + item.Reason
+		}
+	}
+	expected := map[ObjectRef]string{
+		{KindSystem, "tst"}:     "blocked: still referenced by node tst-0001",
+		{KindNetwork, "prod"}:   "blocked: still referenced by system tst",
+		{KindNetwork, "unused"}: "delete: missing from backup",
+	}
+	for ref, reason := range expected {
+		if reasons[ref] != reason {
+			t.Errorf("expected %s %s to be %q, got %q", ref.Kind, ref.ID, reason, reasons[ref])
+		}
+	}
+	if checkDeleteReferences(plan.Items, live.Objects(KindNode), map[string]Object{}) != 0 {
+		t.Errorf("expected no deletes left to block")
+	}
+}
+
+func TestNewPlanPruneIncremental(t *testing.T) {
+	since := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	backupData := &InventoryBackup{
+		Since:      &since,
+		Tombstones: []ObjectRef{{KindNode, "tst-0002"}},
+	}
+	live := &InventoryBackup{
+		Nodes: []*types.Node{{InventoryID: "tst-0001"}, {InventoryID: "tst-0002"}},
+	}
+
+	plan, err := NewPlan(backupData, live, PlanOptions{Prune: Kinds})
+	if err != nil {
+		t.Fatalf("unable to build plan: %v", err)
+	}
+	if len(plan.Items) != 1 || plan.Items[0].ID != "tst-0002" || plan.Items[0].Reason != "deleted in backup" {
+		t.Errorf("expected only the tombstoned node to be deleted, got %v", plan.Items)
+	}
+}
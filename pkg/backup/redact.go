@@ -0,0 +1,285 @@
+package backup
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+// RedactedPrefix starts every value replaced by a redaction policy.
+const RedactedPrefix = "<redacted"
+
+// RedactMode is how a redaction policy replaces values.
+type RedactMode string
+
+const (
+	// RedactMarker replaces values with a fixed marker.
+	RedactMarker RedactMode = "marker"
+	// RedactHMAC replaces values with an HMAC-SHA256 fingerprint, so changes
+	// to redacted values can still be detected.
+	RedactHMAC RedactMode = "hmac"
+)
+
+// RedactionPolicy selects the metadata values removed from backups.
+type RedactionPolicy struct {
+	// Keys are case insensitive glob patterns matched against metadata keys
+	// at any depth.
+	Keys []string
+	// Mode defaults to RedactMarker
+	Mode RedactMode
+	// HMACKey is required for RedactHMAC
+	HMACKey string `mapstructure:"hmac_key"`
+}
+
+// Validate checks that the policy can be applied.
+func (p *RedactionPolicy) Validate() error {
+	for _, pattern := range p.Keys {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid key pattern '%s': %v", pattern, err)
+		}
+	}
+
+	switch p.Mode {
+	case "", RedactMarker:
+	case RedactHMAC:
+		if p.HMACKey == "" {
+			return fmt.Errorf("an hmac key is required to fingerprint redacted values")
+		}
+	default:
+		return fmt.Errorf("unknown redaction mode '%s', must be %s or %s", p.Mode, RedactMarker, RedactHMAC)
+	}
+	return nil
+}
+
+func (p *RedactionPolicy) matches(key string) bool {
+	for _, pattern := range p.Keys {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(key)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RedactionPolicy) placeholder(value interface{}) (string, error) {
+	if p.Mode != RedactHMAC {
+		return RedactedPrefix + ">", nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal redacted value: %v", err)
+	}
+	mac := hmac.New(sha256.New, []byte(p.HMACKey))
+	mac.Write(data)
+	return fmt.Sprintf("%s:hmac-sha256:%s>", RedactedPrefix, hex.EncodeToString(mac.Sum(nil))), nil
+}
+
+// Apply returns a copy of the backup with the values of matching keys in the
+// metadata of every object replaced by a placeholder, and the number of
+// values replaced.
+func (p *RedactionPolicy) Apply(b *InventoryBackup) (*InventoryBackup, int, error) {
+	if err := p.Validate(); err != nil {
+		return nil, 0, err
+	}
+
+	doc, err := ToGeneric(b)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var count int
+	err = p.redactMetadata(doc, false, &count)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to marshal redacted backup: %v", err)
+	}
+
+	redacted := &InventoryBackup{}
+	err = json.Unmarshal(data, redacted)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to unmarshal redacted backup: %v", err)
+	}
+	redacted.MigratedFrom = b.MigratedFrom
+	redacted.Unverified = b.Unverified
+	return redacted, count, nil
+}
+
+// redactMetadata walks a generic document, redacting values in maps below a
+// metadata key.
+func (p *RedactionPolicy) redactMetadata(value interface{}, inMetadata bool, count *int) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if inMetadata && p.matches(key) {
+				placeholder, err := p.placeholder(child)
+				if err != nil {
+					return err
+				}
+				v[key] = placeholder
+				*count++
+				continue
+			}
+			err := p.redactMetadata(child, inMetadata || strings.EqualFold(key, "metadata"), count)
+			if err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			err := p.redactMetadata(child, inMetadata, count)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RedactedFields returns the fields of an object that hold redacted
+// placeholders.
+func RedactedFields(obj interface{}) ([]string, error) {
+	doc, err := ToGeneric(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := []string{}
+	var walk func(prefix string, value interface{})
+	walk = func(prefix string, value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			for key, child := range v {
+				walk(joinField(prefix, key), child)
+			}
+		case []interface{}:
+			for i, child := range v {
+				walk(joinField(prefix, fmt.Sprintf("%d", i)), child)
+			}
+		case string:
+			if strings.HasPrefix(v, RedactedPrefix) {
+				fields = append(fields, prefix)
+			}
+		}
+	}
+	walk("", doc)
+	sort.Strings(fields)
+	return fields, nil
+}
+
+// fingerprintDiffers returns true if an hmac placeholder was made from a
+// different value than live.  Placeholders that can't be compared, because
+// they are markers or the policy has no hmac key, never differ.
+func (p *RedactionPolicy) fingerprintDiffers(placeholder string, live interface{}) bool {
+	if p == nil || p.Mode != RedactHMAC || p.HMACKey == "" || !strings.HasPrefix(placeholder, RedactedPrefix+":hmac-sha256:") {
+		return false
+	}
+	fingerprint, err := p.placeholder(live)
+	return err == nil && !hmac.Equal([]byte(fingerprint), []byte(placeholder))
+}
+
+// restoreRedacted returns a copy of obj with each redacted placeholder
+// replaced by the value of the same field in the live object.  It also
+// returns the redacted fields without a live value, and the fields whose hmac
+// fingerprint shows the redacted value differed from the live value.
+func restoreRedacted(obj, live Object, policy *RedactionPolicy) (Object, []string, []string, error) {
+	fields, err := RedactedFields(obj)
+	if err != nil || len(fields) == 0 {
+		return obj, nil, nil, err
+	}
+
+	doc, err := ToGeneric(obj)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var liveDoc interface{}
+	if live != nil {
+		liveDoc, err = ToGeneric(live)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	missing, differs := []string{}, []string{}
+	var restore func(prefix string, value, liveValue interface{}) interface{}
+	restore = func(prefix string, value, liveValue interface{}) interface{} {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			liveMap, _ := liveValue.(map[string]interface{})
+			for key, child := range v {
+				v[key] = restore(joinField(prefix, key), child, liveMap[key])
+			}
+		case []interface{}:
+			liveList, _ := liveValue.([]interface{})
+			for i, child := range v {
+				var liveChild interface{}
+				if i < len(liveList) {
+					liveChild = liveList[i]
+				}
+				v[i] = restore(joinField(prefix, fmt.Sprintf("%d", i)), child, liveChild)
+			}
+		case string:
+			if !strings.HasPrefix(v, RedactedPrefix) {
+				break
+			}
+			if liveValue == nil {
+				missing = append(missing, prefix)
+				break
+			}
+			if policy.fingerprintDiffers(v, liveValue) {
+				differs = append(differs, prefix)
+			}
+			return liveValue
+		}
+		return value
+	}
+	doc = restore("", doc, liveDoc)
+	sort.Strings(missing)
+	sort.Strings(differs)
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("unable to marshal restored object: %v", err)
+	}
+	restored, err := unmarshalObject(obj, data)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return restored, missing, differs, nil
+}
+
+// unmarshalObject decodes data into a new object of the same type as obj.
+func unmarshalObject(obj Object, data []byte) (Object, error) {
+	var restored Object
+	switch obj.(type) {
+	case *types.Node:
+		restored = &types.Node{}
+	case *types.System:
+		restored = &types.System{}
+	case *types.Network:
+		restored = &types.Network{}
+	case Reservation:
+		reservation := &types.IPReservation{}
+		if err := json.Unmarshal(data, reservation); err != nil {
+			return nil, fmt.Errorf("unable to unmarshal restored object: %v", err)
+		}
+		return Reservation{reservation}, nil
+	default:
+		return nil, fmt.Errorf("unsupported object type %T", obj)
+	}
+
+	if err := json.Unmarshal(data, restored); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal restored object: %v", err)
+	}
+	return restored, nil
+}
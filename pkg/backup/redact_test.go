@@ -0,0 +1,109 @@
+package backup
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+func TestRedactionPolicy(t *testing.T) {
+	b := &InventoryBackup{
+		Nodes: []*types.Node{{
+			InventoryID: "tst-0001",
+			Metadata: types.Metadata{
+				"serial":       "abc123",
+				"BMC_Password": "hunter2",
+				"ipmi":         map[string]interface{}{"password": "hunter2", "user": "admin"},
+			},
+		}},
+		Systems: []*types.System{{Name: "tst", Metadata: types.Metadata{"password": "hunter2"}}},
+	}
+
+	policy := &RedactionPolicy{Keys: []string{"*password*"}}
+	redacted, count, err := policy.Apply(b)
+	if err != nil {
+		t.Fatalf("unable to redact backup: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("expected 3 redacted values, got %d", count)
+	}
+
+	metadata := redacted.Nodes[0].Metadata
+	if metadata["BMC_Password"] != "<redacted>" || metadata["serial"] != "abc123" {
+		t.Errorf("unexpected node metadata: %v", metadata)
+	}
+	if ipmi := metadata["ipmi"].(map[string]interface{}); ipmi["password"] != "<redacted>" || ipmi["user"] != "admin" {
+		t.Errorf("nested metadata wasn't redacted: %v", ipmi)
+	}
+	if b.Nodes[0].Metadata["BMC_Password"] != "hunter2" {
+		t.Errorf("original backup was modified")
+	}
+
+	policy = &RedactionPolicy{Keys: []string{"*password"}, Mode: RedactHMAC, HMACKey: "secret"}
+	fingerprinted, _, err := policy.Apply(b)
+	if err != nil {
+		t.Fatalf("unable to redact backup: %v", err)
+	}
+	fingerprint := fingerprinted.Nodes[0].Metadata["BMC_Password"].(string)
+	if !strings.HasPrefix(fingerprint, "<redacted:hmac-sha256:") || fingerprint != fingerprinted.Systems[0].Metadata["password"] {
+		t.Errorf("expected equal values to have the same fingerprint: %s, %s", fingerprint, fingerprinted.Systems[0].Metadata["password"])
+	}
+
+	if _, _, err = (&RedactionPolicy{Mode: RedactHMAC}).Apply(b); err == nil {
+		t.Errorf("expected an error for an hmac policy without a key")
+	}
+
+	plan, err := NewPlan(redacted, &InventoryBackup{}, PlanOptions{})
+	if err != nil {
+		t.Fatalf("unable to build plan: %v", err)
+	}
+	for _, item := range plan.Items {
+		if item.Action != ActionRedacted || !strings.Contains(item.Reason, "no live value to keep for redacted Metadata.") {
+			t.Errorf("expected %s %s to be redacted, got %s (%s)", item.Kind, item.ID, item.Action, item.Reason)
+		}
+	}
+
+	// objects that only differ from live by redaction are identical
+	for _, r := range []*InventoryBackup{redacted, fingerprinted} {
+		plan, err = NewPlan(r, b, PlanOptions{Redaction: policy})
+		if err != nil {
+			t.Fatalf("unable to build plan: %v", err)
+		}
+		for _, item := range plan.Items {
+			if item.Action != ActionSkipIdentical || item.Reason != "" {
+				t.Errorf("expected %s %s to be identical, got %s (%s)", item.Kind, item.ID, item.Action, item.Reason)
+			}
+		}
+	}
+
+	// other changes are written with the live values of redacted fields
+	changed := &InventoryBackup{
+		Nodes: []*types.Node{{
+			InventoryID: "tst-0001",
+			Metadata: types.Metadata{
+				"serial":       "abc124",
+				"BMC_Password": "hunter3",
+				"ipmi":         map[string]interface{}{"password": "hunter2", "user": "admin"},
+			},
+			LastUpdated: time.Now(),
+		}},
+	}
+	changed, _, err = policy.Apply(changed)
+	if err != nil {
+		t.Fatalf("unable to redact backup: %v", err)
+	}
+	plan, err = NewPlan(changed, b, PlanOptions{Redaction: policy})
+	if err != nil {
+		t.Fatalf("unable to build plan: %v", err)
+	}
+	item := plan.Items[0]
+	if item.Action != ActionUpdate || len(item.Changes) != 2 || item.Changes[1].Field != "Metadata.serial" ||
+		item.Reason != "redacted Metadata.BMC_Password differs from the live value, keeping the live value" {
+		t.Errorf("unexpected plan item: %s (%s) %v", item.Action, item.Reason, item.Changes)
+	}
+	if node := item.Object.(*types.Node); node.Metadata["BMC_Password"] != "hunter2" {
+		t.Errorf("live value of redacted field wasn't kept: %v", node.Metadata)
+	}
+}
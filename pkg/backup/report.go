@@ -0,0 +1,110 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// Outcome records what happened to an object during an import.
+type Outcome string
+
+const (
+	OutcomeCreated Outcome = "created"
+	OutcomeUpdated Outcome = "updated"
+	OutcomeDeleted Outcome = "deleted"
+	OutcomeSkipped Outcome = "skipped"
+	OutcomeFailed  Outcome = "failed"
+)
+
+var outcomes = []Outcome{OutcomeCreated, OutcomeUpdated, OutcomeDeleted, OutcomeSkipped, OutcomeFailed}
+
+// Result is the outcome of importing a single object.
+type Result struct {
+	Kind    Kind    `json:"kind"`
+	ID      string  `json:"id"`
+	Action  Action  `json:"action"`
+	Outcome Outcome `json:"outcome"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// Report collects the results of an import.
+type Report struct {
+	Results []*Result `json:"results"`
+}
+
+// NewReport returns an empty report.
+func NewReport() *Report {
+	return &Report{Results: []*Result{}}
+}
+
+// Add records the result of applying a plan item.  err is the error returned
+// while writing the object, if any.
+func (r *Report) Add(item *PlanItem, err error) *Result {
+	result := &Result{Kind: item.Kind, ID: item.ID, Action: item.Action}
+	switch {
+	case err != nil:
+		result.Outcome = OutcomeFailed
+		result.Error = err.Error()
+	case item.Action == ActionBlocked || item.Action == ActionRedacted:
+		result.Outcome = OutcomeFailed
+		result.Error = item.Reason
+	case item.Action == ActionCreate:
+		result.Outcome = OutcomeCreated
+	case item.Action == ActionUpdate:
+		result.Outcome = OutcomeUpdated
+	case item.Action == ActionDelete:
+		result.Outcome = OutcomeDeleted
+	default:
+		result.Outcome = OutcomeSkipped
+	}
+	r.Results = append(r.Results, result)
+	return result
+}
+
+// Failed returns the number of objects that could not be imported.
+func (r *Report) Failed() int {
+	var failed int
+	for _, result := range r.Results {
+		if result.Outcome == OutcomeFailed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// PrintSummary writes a table with the number of objects of each kind per
+// outcome, followed by the errors for any failed objects.
+func (r *Report) PrintSummary(w io.Writer) {
+	counts := make(map[Kind]map[Outcome]int)
+	for _, result := range r.Results {
+		if counts[result.Kind] == nil {
+			counts[result.Kind] = make(map[Outcome]int)
+		}
+		counts[result.Kind][result.Outcome]++
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "KIND")
+	for _, outcome := range outcomes {
+		fmt.Fprintf(tw, "\t%s", outcome)
+	}
+	fmt.Fprintf(tw, "\n")
+	for _, kind := range Kinds {
+		if counts[kind] == nil {
+			continue
+		}
+		fmt.Fprintf(tw, "%s", kind)
+		for _, outcome := range outcomes {
+			fmt.Fprintf(tw, "\t%d", counts[kind][outcome])
+		}
+		fmt.Fprintf(tw, "\n")
+	}
+	tw.Flush()
+
+	for _, result := range r.Results {
+		if result.Outcome == OutcomeFailed {
+			fmt.Fprintf(w, "%s %s (%s): %s\n", result.Kind, result.ID, result.Action, result.Error)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package backup
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	report := NewReport()
+	report.Add(&PlanItem{Kind: KindNode, ID: "tst-0001", Action: ActionCreate}, nil)
+	report.Add(&PlanItem{Kind: KindNode, ID: "tst-0002", Action: ActionUpdate}, fmt.Errorf("boom"))
+	report.Add(&PlanItem{Kind: KindNode, ID: "tst-0003", Action: ActionBlocked, Reason: "missing system"}, nil)
+	report.Add(&PlanItem{Kind: KindSystem, ID: "tst", Action: ActionSkipIdentical}, nil)
+
+	if failed := report.Failed(); failed != 2 {
+		t.Errorf("expected 2 failures, got %d", failed)
+	}
+
+	out := &bytes.Buffer{}
+	report.PrintSummary(out)
+	if !strings.Contains(out.String(), "node    1        0        0        0        2") {
+		t.Errorf("unexpected summary:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "node tst-0003 (blocked): missing system") {
+		t.Errorf("summary is missing failure details:\n%s", out.String())
+	}
+}
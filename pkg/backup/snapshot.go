@@ -0,0 +1,216 @@
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// IndexFilename is the name of the index file in a snapshot directory.
+const IndexFilename = "index.json"
+
+const snapshotTimeFormat = "20060102T150405Z"
+
+var snapshotExtensions = map[Compression]string{
+	CompressionNone: ".json",
+	CompressionGzip: ".json.gz",
+	CompressionZstd: ".json.zst",
+}
+
+// ParseCompression validates the name of a compression algorithm.
+func ParseCompression(name string) (Compression, error) {
+	switch name {
+	case "none", "":
+		return CompressionNone, nil
+	case string(CompressionGzip), string(CompressionZstd):
+		return Compression(name), nil
+	}
+	return "", fmt.Errorf("unknown compression '%s', must be one of none, %s or %s", name, CompressionGzip, CompressionZstd)
+}
+
+// Snapshot describes a backup stored in a snapshot directory.
+type Snapshot struct {
+	Filename string       `json:"filename"`
+	Date     time.Time    `json:"date"`
+	Counts   map[Kind]int `json:"counts"`
+}
+
+// Counts returns the number of objects of each kind in the backup.
+func (b *InventoryBackup) Counts() map[Kind]int {
+	counts := make(map[Kind]int)
+	for _, kind := range Kinds {
+		counts[kind] = len(b.Objects(kind))
+	}
+	return counts
+}
+
+// ReadIndex returns the snapshots listed in the index of a snapshot directory,
+// oldest first.  A directory without an index has no snapshots.
+func ReadIndex(dir string) ([]*Snapshot, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, IndexFilename))
+	if os.IsNotExist(err) {
+		return []*Snapshot{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read snapshot index: %v", err)
+	}
+
+	snapshots := []*Snapshot{}
+	err = json.Unmarshal(data, &snapshots)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse snapshot index: %v", err)
+	}
+	sortSnapshots(snapshots)
+	return snapshots, nil
+}
+
+// WriteIndex replaces the index of a snapshot directory.
+func WriteIndex(dir string, snapshots []*Snapshot) error {
+	sortSnapshots(snapshots)
+	data, err := json.MarshalIndent(snapshots, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal snapshot index: %v", err)
+	}
+	return writeFileAtomic(filepath.Join(dir, IndexFilename), data)
+}
+
+func sortSnapshots(snapshots []*Snapshot) {
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Date.Before(snapshots[j].Date)
+	})
+}
+
+// WriteSnapshot writes a backup to a file named after its backup date in dir
+// and adds it to the index.
+func WriteSnapshot(dir string, b *InventoryBackup, compression Compression, keys *Keys) (*Snapshot, error) {
+	snapshots, err := ReadIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{
+		Filename: "inventory-" + b.BackupDate.UTC().Format(snapshotTimeFormat) + snapshotExtensions[compression],
+		Date:     b.BackupDate,
+		Counts:   b.Counts(),
+	}
+	for _, s := range snapshots {
+		if s.Filename == snapshot.Filename {
+			return nil, fmt.Errorf("snapshot %s already exists", snapshot.Filename)
+		}
+	}
+
+	err = WriteFile(filepath.Join(dir, snapshot.Filename), b, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	err = WriteIndex(dir, append(snapshots, snapshot))
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// Retention is a grandfather-father-son retention policy.  The newest snapshot
+// of each of the last Hourly hours, Daily days, Weekly weeks and Monthly
+// months is kept.  A policy that keeps nothing doesn't remove any snapshots.
+type Retention struct {
+	Hourly  int
+	Daily   int
+	Weekly  int
+	Monthly int
+}
+
+func (r Retention) empty() bool {
+	return r.Hourly <= 0 && r.Daily <= 0 && r.Weekly <= 0 && r.Monthly <= 0
+}
+
+// Keep returns the snapshots retained by the policy.  The newest snapshot is
+// always kept.
+func (r Retention) Keep(snapshots []*Snapshot) map[*Snapshot]bool {
+	keep := make(map[*Snapshot]bool)
+	if len(snapshots) == 0 {
+		return keep
+	}
+
+	newest := make([]*Snapshot, len(snapshots))
+	copy(newest, snapshots)
+	sort.SliceStable(newest, func(i, j int) bool {
+		return newest[i].Date.After(newest[j].Date)
+	})
+
+	if r.empty() {
+		for _, s := range newest {
+			keep[s] = true
+		}
+		return keep
+	}
+	keep[newest[0]] = true
+
+	periods := []struct {
+		count  int
+		bucket func(time.Time) string
+	}{
+		{r.Hourly, func(t time.Time) string { return t.Format("2006-01-02T15") }},
+		{r.Daily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{r.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{r.Monthly, func(t time.Time) string { return t.Format("2006-01") }},
+	}
+	for _, period := range periods {
+		seen := make(map[string]bool)
+		for _, s := range newest {
+			if len(seen) >= period.count {
+				break
+			}
+			bucket := period.bucket(s.Date.UTC())
+			if !seen[bucket] {
+				seen[bucket] = true
+				keep[s] = true
+			}
+		}
+	}
+	return keep
+}
+
+// PruneSnapshots removes the snapshots that aren't retained by the policy from
+// dir and its index, returning the removed snapshots.
+func PruneSnapshots(dir string, retention Retention) ([]*Snapshot, error) {
+	snapshots, err := ReadIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := retention.Keep(snapshots)
+	kept := []*Snapshot{}
+	removed := []*Snapshot{}
+	for _, s := range snapshots {
+		if keep[s] {
+			kept = append(kept, s)
+		} else {
+			removed = append(removed, s)
+		}
+	}
+	if len(removed) == 0 {
+		return removed, nil
+	}
+
+	// update the index first, so it never lists a snapshot that doesn't exist
+	err = WriteIndex(dir, kept)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range removed {
+		err = os.Remove(filepath.Join(dir, s.Filename))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("unable to remove snapshot %s: %v", s.Filename, err)
+		}
+	}
+	return removed, nil
+}
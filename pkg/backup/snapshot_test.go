@@ -0,0 +1,94 @@
+package backup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+func TestWriteSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshots")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	date := time.Date(2019, 3, 10, 12, 0, 0, 0, time.UTC)
+	b := &InventoryBackup{BackupDate: date, Nodes: []*types.Node{{InventoryID: "tst-0001"}}}
+	snapshot, err := WriteSnapshot(dir, b, CompressionGzip, nil)
+	if err != nil {
+		t.Fatalf("unable to write snapshot: %v", err)
+	}
+	if snapshot.Filename != "inventory-20190310T120000Z.json.gz" || snapshot.Counts[KindNode] != 1 {
+		t.Errorf("unexpected snapshot: %+v", snapshot)
+	}
+
+	if _, err = WriteSnapshot(dir, b, CompressionGzip, nil); err == nil {
+		t.Errorf("expected an error writing a snapshot with the same date")
+	}
+
+	b.BackupDate = date.Add(-time.Hour)
+	if _, err = WriteSnapshot(dir, b, CompressionNone, nil); err != nil {
+		t.Fatalf("unable to write snapshot: %v", err)
+	}
+
+	snapshots, err := ReadIndex(dir)
+	if err != nil {
+		t.Fatalf("unable to read index: %v", err)
+	}
+	if len(snapshots) != 2 || snapshots[0].Filename != "inventory-20190310T110000Z.json" {
+		t.Fatalf("expected two snapshots oldest first, got %+v", snapshots)
+	}
+
+	restored, err := ReadFile(filepath.Join(dir, snapshots[1].Filename), nil)
+	if err != nil {
+		t.Fatalf("unable to read snapshot: %v", err)
+	}
+	if len(restored.Nodes) != 1 {
+		t.Errorf("expected one node in snapshot, got %d", len(restored.Nodes))
+	}
+
+	removed, err := PruneSnapshots(dir, Retention{Hourly: 1})
+	if err != nil {
+		t.Fatalf("unable to prune snapshots: %v", err)
+	}
+	if len(removed) != 1 || removed[0].Filename != snapshots[0].Filename {
+		t.Errorf("expected the oldest snapshot to be removed, got %+v", removed)
+	}
+	if _, err = os.Stat(filepath.Join(dir, snapshots[0].Filename)); !os.IsNotExist(err) {
+		t.Errorf("expected pruned snapshot file to be removed: %v", err)
+	}
+}
+
+func TestRetentionKeep(t *testing.T) {
+	dates := []time.Time{
+		time.Date(2019, 1, 15, 0, 0, 0, 0, time.UTC),
+		time.Date(2019, 2, 20, 0, 0, 0, 0, time.UTC),
+		time.Date(2019, 3, 9, 10, 0, 0, 0, time.UTC),
+		time.Date(2019, 3, 9, 23, 0, 0, 0, time.UTC),
+		time.Date(2019, 3, 10, 9, 0, 0, 0, time.UTC),
+		time.Date(2019, 3, 10, 10, 0, 0, 0, time.UTC),
+		time.Date(2019, 3, 10, 11, 0, 0, 0, time.UTC),
+		time.Date(2019, 3, 10, 12, 0, 0, 0, time.UTC),
+	}
+	snapshots := []*Snapshot{}
+	for _, date := range dates {
+		snapshots = append(snapshots, &Snapshot{Date: date})
+	}
+
+	keep := Retention{Hourly: 3, Daily: 2, Monthly: 2}.Keep(snapshots)
+	expected := []bool{false, true, false, true, false, true, true, true}
+	for i, s := range snapshots {
+		if keep[s] != expected[i] {
+			t.Errorf("expected keep=%t for snapshot at %s", expected[i], s.Date)
+		}
+	}
+
+	if keep := (Retention{}).Keep(snapshots); len(keep) != len(snapshots) {
+		t.Errorf("expected an empty policy to keep every snapshot, kept %d", len(keep))
+	}
+}
@@ -0,0 +1,126 @@
+package backup
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+// Violation is a consistency problem found in a backup.
+type Violation struct {
+	Kind    Kind   `json:"kind"`
+	ID      string `json:"id"`
+	Message string `json:"message"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s %s: %s", v.Kind, v.ID, v.Message)
+}
+
+// Verify checks the referential integrity of a backup without consulting the
+// api.  It reports duplicate IDs, nodes that reference unknown systems, roles,
+// environments or networks, MAC addresses assigned to more than one node and
+// overlapping subnets.
+func Verify(b *InventoryBackup) []Violation {
+	violations := []Violation{}
+	violations = append(violations, duplicateIDs(b)...)
+
+	systems := b.Objects(KindSystem)
+	networks := b.Objects(KindNetwork)
+	macOwners := make(map[string]string)
+	for _, node := range b.Nodes {
+		for _, err := range nodeReferenceErrors(node, systems, networks) {
+			violations = append(violations, Violation{KindNode, node.ID(), err.Error()})
+		}
+
+		for _, mac := range nodeMACs(node) {
+			if owner, ok := macOwners[mac]; ok {
+				violations = append(violations, Violation{KindNode, node.ID(), fmt.Sprintf("mac %s is also assigned to node %s", mac, owner)})
+				continue
+			}
+			macOwners[mac] = node.ID()
+		}
+	}
+
+	violations = append(violations, overlappingSubnets(b.Networks)...)
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		a, b := ObjectRef{violations[i].Kind, violations[i].ID}, ObjectRef{violations[j].Kind, violations[j].ID}
+		return lessRef(a, b)
+	})
+	return violations
+}
+
+// nodeMACs returns the distinct MAC addresses of a node's interfaces, sorted.
+func nodeMACs(node *types.Node) []string {
+	seen := make(map[string]bool)
+	macs := []string{}
+	for _, iface := range node.Networks {
+		if iface == nil {
+			continue
+		}
+		for _, mac := range iface.NICs {
+			if !seen[mac.String()] {
+				seen[mac.String()] = true
+				macs = append(macs, mac.String())
+			}
+		}
+	}
+	sort.Strings(macs)
+	return macs
+}
+
+func duplicateIDs(b *InventoryBackup) []Violation {
+	violations := []Violation{}
+	seen := make(map[ObjectRef]bool)
+	check := func(kind Kind, id string) {
+		ref := ObjectRef{kind, id}
+		if seen[ref] {
+			violations = append(violations, Violation{kind, id, "duplicate id"})
+		}
+		seen[ref] = true
+	}
+
+	for _, node := range b.Nodes {
+		check(KindNode, node.ID())
+	}
+	for _, system := range b.Systems {
+		check(KindSystem, system.ID())
+	}
+	for _, network := range b.Networks {
+		check(KindNetwork, network.ID())
+	}
+	for _, reservation := range b.IPReservations {
+		check(KindIPReservation, Reservation{reservation}.ID())
+	}
+	return violations
+}
+
+func overlappingSubnets(networks []*types.Network) []Violation {
+	type namedSubnet struct {
+		network string
+		cidr    *net.IPNet
+	}
+
+	violations := []Violation{}
+	subnets := []namedSubnet{}
+	for _, network := range networks {
+		for _, subnet := range network.Subnets {
+			if subnet == nil || subnet.Cidr == nil {
+				violations = append(violations, Violation{KindNetwork, network.ID(), "subnet without a cidr"})
+				continue
+			}
+
+			for _, other := range subnets {
+				if other.cidr.Contains(subnet.Cidr.IP) || subnet.Cidr.Contains(other.cidr.IP) {
+					message := fmt.Sprintf("subnet %s overlaps %s on network %s", subnet.Cidr, other.cidr, other.network)
+					violations = append(violations, Violation{KindNetwork, network.ID(), message})
+				}
+			}
+			subnets = append(subnets, namedSubnet{network.ID(), subnet.Cidr})
+		}
+	}
+	return violations
+}
@@ -0,0 +1,50 @@
+package backup
+
+import (
+	"net"
+	"testing"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+func TestVerify(t *testing.T) {
+	_, subnetA, _ := net.ParseCIDR("10.0.0.0/16")
+	_, subnetB, _ := net.ParseCIDR("10.0.1.0/24")
+	mac, _ := net.ParseMAC("00:01:02:03:04:05")
+	iface := &types.NetworkInterface{NICs: []net.HardwareAddr{mac}}
+
+	b := &InventoryBackup{
+		Nodes: []*types.Node{
+			{InventoryID: "tst-0001", System: "tst", Role: "compute", Environment: "prod", Networks: types.NICInfoMap{"a": iface}},
+			{InventoryID: "tst-0002", System: "tst", Role: "storage", Environment: "dev", Networks: types.NICInfoMap{"a": iface, "c": iface}},
+			{InventoryID: "tst-0002", System: "missing"},
+		},
+		Systems: []*types.System{
+			{Name: "tst", Roles: []string{"compute"}, Environments: map[string]*types.Environment{"prod": {}}},
+		},
+		Networks: []*types.Network{
+			{Name: "a", Subnets: types.SubnetList{{Cidr: subnetA}}},
+			{Name: "b", Subnets: types.SubnetList{{Cidr: subnetB}}},
+		},
+	}
+
+	expected := []string{
+		"network b: subnet 10.0.1.0/24 overlaps 10.0.0.0/16 on network a",
+		"node tst-0002: duplicate id",
+		"node tst-0002: role storage isn't defined for system tst",
+		"node tst-0002: environment dev isn't defined for system tst",
+		"node tst-0002: network c doesn't exist",
+		"node tst-0002: mac 00:01:02:03:04:05 is also assigned to node tst-0001",
+		"node tst-0002: system missing doesn't exist",
+	}
+
+	violations := Verify(b)
+	if len(violations) != len(expected) {
+		t.Fatalf("expected %d violations, got %d: %v", len(expected), len(violations), violations)
+	}
+	for i, violation := range violations {
+		if violation.String() != expected[i] {
+			t.Errorf("expected '%s', got '%s'", expected[i], violation)
+		}
+	}
+}
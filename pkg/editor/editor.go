@@ -0,0 +1,97 @@
+// Package editor edits text in the user's editor, in the style of kubectl edit.
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// DefaultEditor is run when neither VISUAL nor EDITOR are set.
+const DefaultEditor = "vi"
+
+// errorPrefix marks the comments added by WithErrors.
+const errorPrefix = "# error: "
+
+// Command returns the editor command line from the environment.
+func Command() []string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if fields := strings.Fields(os.Getenv(env)); len(fields) > 0 {
+			return fields
+		}
+	}
+	return []string{DefaultEditor}
+}
+
+// Edit writes data to a temporary file with the given suffix, opens it in the
+// editor and returns the edited contents.
+func Edit(data []byte, suffix string) ([]byte, error) {
+	f, err := ioutil.TempFile("", "inventory-edit-*"+suffix)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temporary file: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to write temporary file: %v", err)
+	}
+
+	command := Command()
+	cmd := exec.Command(command[0], append(command[1:], f.Name())...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("unable to run editor %s: %v", strings.Join(command, " "), err)
+	}
+
+	edited, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("unable to read edited file: %v", err)
+	}
+	return edited, nil
+}
+
+// WithErrors replaces the error comments at the top of data with one comment
+// for each error.
+func WithErrors(data []byte, errs []error) []byte {
+	out := &bytes.Buffer{}
+	for _, err := range errs {
+		for _, line := range strings.Split(err.Error(), "\n") {
+			out.WriteString(errorPrefix + line + "\n")
+		}
+	}
+	out.Write(stripErrors(data))
+	return out.Bytes()
+}
+
+func stripErrors(data []byte) []byte {
+	for bytes.HasPrefix(data, []byte(errorPrefix)) {
+		end := bytes.IndexByte(data, '\n')
+		if end < 0 {
+			return nil
+		}
+		data = data[end+1:]
+	}
+	return data
+}
+
+// IsEmpty returns true if data only holds comments and whitespace, meaning
+// the edit was abandoned.
+func IsEmpty(data []byte) bool {
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			return false
+		}
+	}
+	return true
+}
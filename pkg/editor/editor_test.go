@@ -0,0 +1,45 @@
+package editor
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestWithErrors(t *testing.T) {
+	data := []byte("# Edit the node below\nsystem: tst\n")
+	withErrors := WithErrors(data, []error{fmt.Errorf("unknown system 'tst'"), fmt.Errorf("two\nlines")})
+	expected := "# error: unknown system 'tst'\n# error: two\n# error: lines\n# Edit the node below\nsystem: tst\n"
+	if string(withErrors) != expected {
+		t.Errorf("unexpected result:\n%s", withErrors)
+	}
+
+	if again := WithErrors(withErrors, []error{fmt.Errorf("fixed")}); string(again) != "# error: fixed\n"+string(data) {
+		t.Errorf("previous errors weren't replaced:\n%s", again)
+	}
+	if cleared := WithErrors(withErrors, nil); string(cleared) != string(data) {
+		t.Errorf("errors weren't cleared:\n%s", cleared)
+	}
+
+	if !IsEmpty([]byte("# error: x\n\n  # comment\n")) || IsEmpty(data) {
+		t.Errorf("IsEmpty returned the wrong result")
+	}
+}
+
+func TestEdit(t *testing.T) {
+	defer os.Setenv("VISUAL", os.Getenv("VISUAL"))
+	os.Setenv("VISUAL", "sed -i s/tst/other/")
+
+	edited, err := Edit([]byte("system: tst\n"), ".yaml")
+	if err != nil {
+		t.Fatalf("unable to edit: %v", err)
+	}
+	if string(edited) != "system: other\n" {
+		t.Errorf("unexpected edit result: %s", edited)
+	}
+
+	os.Setenv("VISUAL", "false")
+	if _, err := Edit([]byte("system: tst\n"), ".yaml"); err == nil {
+		t.Errorf("expected an error when the editor fails")
+	}
+}
@@ -0,0 +1,215 @@
+package ingestlib
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	inventorytypes "github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+// MissingFields lists the required node fields that haven't been set.
+func MissingFields(node *inventorytypes.Node) []string {
+	missing := []string{}
+	if node.InventoryID == "" {
+		missing = append(missing, "inventory id")
+	}
+	location := node.ChassisLocation
+	if location == nil {
+		location = &inventorytypes.ChassisLocation{}
+	}
+	if location.Building == "" {
+		missing = append(missing, "building")
+	}
+	if location.Room == "" {
+		missing = append(missing, "room")
+	}
+	if location.Rack == "" {
+		missing = append(missing, "rack")
+	}
+	if node.System == "" {
+		missing = append(missing, "system")
+	}
+	if node.Role == "" {
+		missing = append(missing, "role")
+	}
+	if node.Environment == "" {
+		missing = append(missing, "environment")
+	}
+	return missing
+}
+
+// ValidateNode checks that a new node has every required field, along with the
+// checks made by CheckNode.
+func ValidateNode(node *inventorytypes.Node, systems []*inventorytypes.System, networks []*inventorytypes.Network) []error {
+	errs := []error{}
+	for _, field := range MissingFields(node) {
+		errs = append(errs, fmt.Errorf("%s is required", field))
+	}
+	return append(errs, CheckNode(node, systems, networks)...)
+}
+
+// ValidateEdit returns the problems CheckNode finds with an edited node that it
+// doesn't find with the original, so nodes that were already incomplete or
+// inconsistent can still be edited.
+func ValidateEdit(original, edited *inventorytypes.Node, systems []*inventorytypes.System, networks []*inventorytypes.Network) []error {
+	existing := make(map[string]bool)
+	for _, err := range CheckNode(original, systems, networks) {
+		existing[err.Error()] = true
+	}
+
+	errs := []error{}
+	for _, err := range CheckNode(edited, systems, networks) {
+		if !existing[err.Error()] {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// CheckNode checks the fields that are set on a node with the same rules used
+// when prompting for them, and that its system, role, environment and networks
+// exist.
+func CheckNode(node *inventorytypes.Node, systems []*inventorytypes.System, networks []*inventorytypes.Network) []error {
+	errs := []error{}
+	if node.InventoryID != "" {
+		if err := validInventoryID(node.InventoryID); err != nil {
+			errs = append(errs, fmt.Errorf("invalid inventory id '%s': %v", node.InventoryID, err))
+		}
+	}
+
+	if node.ChassisLocation != nil {
+		if node.Rack != "" {
+			if err := validRack(node.Rack); err != nil {
+				errs = append(errs, fmt.Errorf("invalid rack '%s': %v", node.Rack, err))
+			}
+		}
+		if err := validRackSpace(strconv.Itoa(int(node.BottomU))); err != nil {
+			errs = append(errs, fmt.Errorf("invalid bottom u %d: %v", node.BottomU, err))
+		}
+	}
+
+	if node.System != "" {
+		system := lookupSystem(systems, node.System)
+		if system == nil {
+			errs = append(errs, fmt.Errorf("unknown system '%s'", node.System))
+		} else {
+			if node.Role != "" && !hasRole(system, node.Role) {
+				errs = append(errs, fmt.Errorf("system %s has no role '%s'", system.ID(), node.Role))
+			}
+			if _, ok := system.Environments[node.Environment]; node.Environment != "" && !ok {
+				errs = append(errs, fmt.Errorf("system %s has no environment '%s'", system.ID(), node.Environment))
+			}
+		}
+	}
+
+	known := make(map[string]bool, len(networks))
+	for _, network := range networks {
+		known[network.ID()] = true
+	}
+	networkIDs := make([]string, 0, len(node.Networks))
+	for id := range node.Networks {
+		networkIDs = append(networkIDs, id)
+	}
+	sort.Strings(networkIDs)
+	for _, id := range networkIDs {
+		if !known[id] {
+			errs = append(errs, fmt.Errorf("unknown network '%s'", id))
+		}
+	}
+	return errs
+}
+
+func lookupSystem(systems []*inventorytypes.System, id string) *inventorytypes.System {
+	for _, system := range systems {
+		if system.ID() == id {
+			return system
+		}
+	}
+	return nil
+}
+
+func hasRole(system *inventorytypes.System, role string) bool {
+	for _, r := range system.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Fields prompted for by PopulateMissing.
+const (
+	PromptInventoryID     = "inventory id"
+	PromptChassisLocation = "chassis location"
+	PromptChassisSubIndex = "chassis sub-index"
+	PromptSystem          = "system"
+	PromptRole            = "role"
+	PromptEnvironment     = "environment"
+)
+
+// MissingPrompts lists the fields PopulateMissing prompts for: those that are
+// missing from the node, or that don't match one of the known systems.
+func (p *NodePopulator) MissingPrompts() []string {
+	prompts := []string{}
+	if p.Node.InventoryID == "" || validInventoryID(p.Node.InventoryID) != nil {
+		prompts = append(prompts, PromptInventoryID)
+	}
+
+	location := p.Node.ChassisLocation
+	if location == nil || location.Building == "" || location.Room == "" || validRack(location.Rack) != nil {
+		prompts = append(prompts, PromptChassisLocation)
+	}
+
+	if p.Node.ChassisSubIndex == "" {
+		prompts = append(prompts, PromptChassisSubIndex)
+	}
+
+	system := lookupSystem(p.Systems, p.Node.System)
+	if system == nil {
+		return append(prompts, PromptSystem, PromptRole, PromptEnvironment)
+	}
+	if !hasRole(system, p.Node.Role) {
+		prompts = append(prompts, PromptRole)
+	}
+	if _, ok := system.Environments[p.Node.Environment]; !ok {
+		prompts = append(prompts, PromptEnvironment)
+	}
+	return prompts
+}
+
+// PopulateMissing prompts for the fields listed by MissingPrompts.
+func (p *NodePopulator) PopulateMissing() error {
+	prompts := make(map[string]bool)
+	for _, prompt := range p.MissingPrompts() {
+		prompts[prompt] = true
+	}
+
+	if prompts[PromptInventoryID] {
+		p.Node.InventoryID = p.ReadInventoryID()
+	}
+	if prompts[PromptChassisLocation] {
+		p.Node.ChassisLocation = p.ReadChassisLocation()
+	}
+	if prompts[PromptChassisSubIndex] {
+		p.Node.ChassisSubIndex = p.ReadChassisSubIndex()
+	}
+
+	system := lookupSystem(p.Systems, p.Node.System)
+	if prompts[PromptSystem] {
+		if len(p.Systems) == 0 {
+			return fmt.Errorf("no systems to choose from")
+		}
+		system = p.ReadSystem(p.Systems)
+		p.Node.System = system.ID()
+	}
+	if prompts[PromptRole] || !hasRole(system, p.Node.Role) {
+		p.Node.Role = p.ReadRole(system)
+	}
+	if _, ok := system.Environments[p.Node.Environment]; prompts[PromptEnvironment] || !ok {
+		p.Node.Environment = p.ReadEnvironment(system)
+	}
+	p.Node.SetTimestamp(time.Now())
+	return nil
+}
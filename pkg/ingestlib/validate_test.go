@@ -0,0 +1,100 @@
+package ingestlib
+
+import (
+	"strings"
+	"testing"
+
+	inventorytypes "github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+func TestValidateNode(t *testing.T) {
+	systems := []*inventorytypes.System{
+		{Name: "test", ShortName: "tst", Roles: []string{"compute"}, Environments: map[string]*inventorytypes.Environment{"prod": {}}},
+	}
+	networks := []*inventorytypes.Network{{Name: "prod"}}
+
+	node := &inventorytypes.Node{
+		InventoryID:     "tst-0001",
+		ChassisLocation: &inventorytypes.ChassisLocation{Building: "bldg", Room: "room", Rack: "aa01", BottomU: 12},
+		System:          "tst",
+		Role:            "compute",
+		Environment:     "prod",
+		Networks:        inventorytypes.NICInfoMap{"prod": &inventorytypes.NetworkInterface{}},
+	}
+	if errs := ValidateNode(node, systems, networks); len(errs) != 0 {
+		t.Errorf("unexpected errors validating node: %v", errs)
+	}
+
+	node.Role = "storage"
+	node.BottomU = 43
+	node.Networks["ib"] = &inventorytypes.NetworkInterface{}
+	errs := ValidateNode(node, systems, networks)
+	if len(errs) != 3 {
+		t.Errorf("expected 3 errors, got %v", errs)
+	}
+
+	incomplete := &inventorytypes.Node{InventoryID: "tst-0002", System: "other"}
+	errs = ValidateNode(incomplete, systems, networks)
+	messages := []string{}
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	expected := "building is required; room is required; rack is required; role is required; environment is required; unknown system 'other'"
+	if strings.Join(messages, "; ") != expected {
+		t.Errorf("unexpected errors: %v", messages)
+	}
+
+	if missing := MissingFields(&inventorytypes.Node{}); len(missing) != 7 {
+		t.Errorf("expected 7 missing fields, got %v", missing)
+	}
+
+	p := &NodePopulator{Node: node, Systems: systems, Networks: networks}
+	node.Role = "compute"
+	node.BottomU = 12
+	if prompts := strings.Join(p.MissingPrompts(), ", "); prompts != "chassis sub-index" {
+		t.Errorf("unexpected prompts for a node without a sub-index: %s", prompts)
+	}
+	node.ChassisSubIndex = "a"
+	if prompts := p.MissingPrompts(); len(prompts) != 0 {
+		t.Errorf("expected no prompts for a complete node, got %v", prompts)
+	}
+
+	p.Node = &inventorytypes.Node{System: "tst", Role: "compute"}
+	if prompts := strings.Join(p.MissingPrompts(), ", "); prompts != "inventory id, chassis location, chassis sub-index, environment" {
+		t.Errorf("unexpected prompts for an incomplete node: %s", prompts)
+	}
+	p.Node = &inventorytypes.Node{}
+	if prompts := len(p.MissingPrompts()); prompts != 6 {
+		t.Errorf("expected 6 prompts for an empty node, got %d", prompts)
+	}
+}
+
+func TestValidateEdit(t *testing.T) {
+	systems := []*inventorytypes.System{
+		{Name: "test", ShortName: "tst", Roles: []string{"compute"}, Environments: map[string]*inventorytypes.Environment{"prod": {}}},
+	}
+	networks := []*inventorytypes.Network{{Name: "prod"}}
+
+	// a node without a location or environment can be edited without adding them
+	original := &inventorytypes.Node{InventoryID: "tst-0001", System: "tst", Role: "compute", Environment: "gone"}
+	edited := &inventorytypes.Node{InventoryID: "tst-0001", System: "tst", Role: "compute", Environment: "gone", ChassisSubIndex: "a"}
+	if errs := ValidateEdit(original, edited, systems, networks); len(errs) != 0 {
+		t.Errorf("unexpected errors editing an incomplete node: %v", errs)
+	}
+	if errs := ValidateNode(edited, systems, networks); len(errs) == 0 {
+		t.Errorf("expected the incomplete node to be invalid for create")
+	}
+
+	edited.Role = "storage"
+	edited.ChassisLocation = &inventorytypes.ChassisLocation{Rack: "rack1", BottomU: 50}
+	edited.Networks = inventorytypes.NICInfoMap{"ib": &inventorytypes.NetworkInterface{}}
+	errs := ValidateEdit(original, edited, systems, networks)
+	messages := []string{}
+	for _, err := range errs {
+		messages = append(messages, strings.SplitN(err.Error(), ":", 2)[0])
+	}
+	expected := "invalid rack 'rack1'; invalid bottom u 50; system tst has no role 'storage'; unknown network 'ib'"
+	if strings.Join(messages, "; ") != expected {
+		t.Errorf("unexpected errors: %v", messages)
+	}
+}
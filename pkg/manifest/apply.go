@@ -0,0 +1,171 @@
+package manifest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PolarGeospatialCenter/inventory-cli/pkg/backup"
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+// Extensions lists the file extensions read from manifest directories.
+var Extensions = []string{".yaml", ".yml", ".json"}
+
+// ReadFiles reads the documents in each manifest file, and in every file with
+// a manifest extension directly inside a directory.  The filename - reads
+// from stdin.
+func ReadFiles(paths []string) ([]*Document, error) {
+	docs := []*Document{}
+	for _, path := range paths {
+		files, err := manifestFiles(path)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			var data []byte
+			if file == "-" {
+				data, err = ioutil.ReadAll(os.Stdin)
+			} else {
+				data, err = ioutil.ReadFile(file)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("unable to read %s: %v", file, err)
+			}
+
+			fileDocs, err := Decode(data, file, "")
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, fileDocs...)
+		}
+	}
+	return docs, nil
+}
+
+func manifestFiles(path string) ([]string, error) {
+	if path == "-" {
+		return []string{path}, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %v", path, err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read directory %s: %v", path, err)
+	}
+	files := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !hasManifestExtension(entry.Name()) {
+			continue
+		}
+		files = append(files, filepath.Join(path, entry.Name()))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func hasManifestExtension(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// Backup collects the documents into a backup, so they can be planned like an
+// import.  An object defined more than once is an error.
+func Backup(docs []*Document) (*backup.InventoryBackup, error) {
+	b := &backup.InventoryBackup{BackupDate: time.Now()}
+	sources := make(map[backup.ObjectRef]string, len(docs))
+	for _, doc := range docs {
+		ref := backup.ObjectRef{Kind: doc.Kind, ID: doc.Object.ID()}
+		if previous, ok := sources[ref]; ok {
+			return nil, fmt.Errorf("%s: %s %s is already defined in %s", doc.Source, ref.Kind, ref.ID, previous)
+		}
+		sources[ref] = doc.Source
+
+		switch obj := doc.Object.(type) {
+		case *types.Node:
+			b.Nodes = append(b.Nodes, obj)
+		case *types.System:
+			b.Systems = append(b.Systems, obj)
+		case *types.Network:
+			b.Networks = append(b.Networks, obj)
+		default:
+			return nil, fmt.Errorf("%s: unsupported object type %T", doc.Source, doc.Object)
+		}
+	}
+	return b, nil
+}
+
+func lastUpdated(obj backup.Object) time.Time {
+	switch o := obj.(type) {
+	case *types.Node:
+		return o.LastUpdated
+	case *types.System:
+		return o.LastUpdated
+	case *types.Network:
+		return o.LastUpdated
+	}
+	return time.Unix(obj.Timestamp(), 0)
+}
+
+type timestamped interface {
+	backup.Object
+	SetTimestamp(time.Time)
+}
+
+// Plan determines the changes needed to make the live inventory match the
+// manifests.  Manifests don't carry timestamps, so objects are compared
+// without them and every object that is written is stamped with the current
+// time.  When prune is set, live objects of the kinds the manifests contain
+// that are selected by pruneScope and aren't in the manifests are deleted, as
+// long as nothing that remains references them.
+func Plan(manifests, live *backup.InventoryBackup, prune bool, pruneScope backup.Filter) (*backup.Plan, error) {
+	for _, kind := range []backup.Kind{backup.KindNetwork, backup.KindSystem, backup.KindNode} {
+		liveObjects := live.Objects(kind)
+		for id, obj := range manifests.Objects(kind) {
+			if liveObj, ok := liveObjects[id]; ok {
+				obj.(timestamped).SetTimestamp(lastUpdated(liveObj))
+			}
+		}
+	}
+
+	opts := backup.PlanOptions{OnConflict: backup.BackupWins}
+	if prune {
+		for _, kind := range []backup.Kind{backup.KindNetwork, backup.KindSystem, backup.KindNode} {
+			if len(manifests.Objects(kind)) > 0 {
+				opts.Prune = append(opts.Prune, kind)
+			}
+		}
+		pruneScope.Kinds = opts.Prune
+		opts.PruneScope = pruneScope
+	}
+
+	plan, err := backup.NewPlan(manifests, live, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, item := range plan.Items {
+		if item.Action == backup.ActionCreate || item.Action == backup.ActionUpdate {
+			item.Object.(timestamped).SetTimestamp(now)
+		}
+	}
+	return plan, nil
+}
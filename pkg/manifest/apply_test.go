@@ -0,0 +1,132 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PolarGeospatialCenter/inventory-cli/pkg/backup"
+	"github.com/PolarGeospatialCenter/inventory-cli/pkg/selector"
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+func TestReadFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "manifest")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"networks.yaml": "kind: network\nname: prod\n",
+		"system.json":   `{"kind": "system", "name": "tst", "roles": ["compute"]}`,
+		"README.md":     "not a manifest",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("unable to write %s: %v", name, err)
+		}
+	}
+
+	docs, err := ReadFiles([]string{dir, filepath.Join(dir, "networks.yaml")})
+	if err != nil {
+		t.Fatalf("unable to read manifests: %v", err)
+	}
+	if len(docs) != 3 {
+		t.Fatalf("expected 3 documents, got %d", len(docs))
+	}
+
+	if _, err := Backup(docs); err == nil {
+		t.Errorf("expected an error for a network defined twice")
+	}
+
+	b, err := Backup(docs[:2])
+	if err != nil {
+		t.Fatalf("unable to build backup: %v", err)
+	}
+	if len(b.Networks) != 1 || len(b.Systems) != 1 {
+		t.Errorf("unexpected objects in backup: %v", b)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	lastWeek := time.Now().Add(-7 * 24 * time.Hour)
+	live := &backup.InventoryBackup{
+		Systems: []*types.System{
+			{Name: "tst", Roles: []string{"compute", "storage"}, LastUpdated: lastWeek},
+			{Name: "old", Roles: []string{"compute"}, LastUpdated: lastWeek},
+			{Name: "other", Roles: []string{"storage"}, LastUpdated: lastWeek},
+		},
+		Networks: []*types.Network{{Name: "prod", LastUpdated: lastWeek}},
+		Nodes: []*types.Node{
+			{InventoryID: "tst-0001", System: "tst", Role: "compute", LastUpdated: lastWeek},
+			{InventoryID: "tst-0002", System: "tst", Role: "compute", LastUpdated: lastWeek},
+			{InventoryID: "tst-0003", System: "tst", Role: "storage", LastUpdated: lastWeek},
+			{InventoryID: "old-0001", System: "old", Role: "compute", LastUpdated: lastWeek,
+				Networks: types.NICInfoMap{"prod": &types.NetworkInterface{}}},
+			{InventoryID: "oth-0001", System: "other", Role: "storage", LastUpdated: lastWeek},
+		},
+	}
+	manifests := &backup.InventoryBackup{
+		Systems: []*types.System{{Name: "tst", Roles: []string{"compute", "storage"}}},
+		Nodes: []*types.Node{
+			{InventoryID: "tst-0001", System: "tst", Role: "compute"},
+			{InventoryID: "tst-0004", System: "tst", Role: "storage"},
+			{InventoryID: "tst-0005", System: "tst", Role: "gpu"},
+		},
+	}
+
+	scope, err := selector.Parse("role=compute")
+	if err != nil {
+		t.Fatalf("unable to parse selector: %v", err)
+	}
+	plan, err := Plan(manifests, live, true, backup.Filter{Selector: scope})
+	if err != nil {
+		t.Fatalf("unable to plan: %v", err)
+	}
+
+	actions := make(map[string]backup.Action)
+	for _, item := range plan.Items {
+		actions[item.ID] = item.Action
+	}
+	expected := map[string]backup.Action{
+		"tst":      backup.ActionSkipIdentical,
+		"tst-0001": backup.ActionSkipIdentical,
+		"tst-0002": backup.ActionDelete,
+		"old":      backup.ActionDelete,
+		"old-0001": backup.ActionDelete,
+		"tst-0004": backup.ActionCreate,
+		"tst-0005": backup.ActionBlocked,
+	}
+	if len(actions) != len(expected) {
+		t.Errorf("unexpected plan: %v", actions)
+	}
+	for id, action := range expected {
+		if actions[id] != action {
+			t.Errorf("expected %s for %s, got %s", action, id, actions[id])
+		}
+	}
+
+	// networks are only pruned once the manifests contain networks
+	manifests.Networks = []*types.Network{{Name: "mgmt"}}
+	plan, err = Plan(manifests, live, true, backup.Filter{Selector: scope})
+	if err != nil {
+		t.Fatalf("unable to plan: %v", err)
+	}
+	actions = make(map[string]backup.Action)
+	for _, item := range plan.Items {
+		actions[item.ID] = item.Action
+	}
+	if actions["prod"] != backup.ActionDelete || actions["mgmt"] != backup.ActionCreate {
+		t.Errorf("expected network prod to be deleted with the only node using it, got %v", actions)
+	}
+
+	if created := manifests.Nodes[1]; created.LastUpdated.Before(time.Now().Add(-time.Minute)) {
+		t.Errorf("created node wasn't timestamped: %v", created.LastUpdated)
+	}
+	if !manifests.Nodes[0].LastUpdated.Equal(lastWeek) {
+		t.Errorf("unchanged node should keep its live timestamp, got %v", manifests.Nodes[0].LastUpdated)
+	}
+}
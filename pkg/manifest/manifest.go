@@ -0,0 +1,251 @@
+// Package manifest reads inventory objects from YAML or JSON files.
+//
+// A manifest holds one or more documents, separated by --- in YAML or
+// concatenated in JSON.  Each document is an object with the same fields as
+// the api, plus a kind field of node, system or network:
+//
+//	kind: node
+//	inventoryid: tst-0001
+//	system: tst
+//	networks:
+//	  prod:
+//	    nics: ["00:11:22:33:44:55"]
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/PolarGeospatialCenter/inventory-cli/pkg/backup"
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Document is a single object read from a manifest.
+type Document struct {
+	Kind   backup.Kind
+	Object backup.Object
+	// Source identifies the document in error messages, as file#index
+	Source string
+}
+
+// Decode reads every document in a manifest.  Documents without a kind are
+// decoded as defaultKind, or rejected if it is empty.  Every object must have
+// an id.
+func Decode(data []byte, source string, defaultKind backup.Kind) ([]*Document, error) {
+	docs, err := decode(data, source, defaultKind)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, doc := range docs {
+		if doc.Object.ID() == "" {
+			return nil, fmt.Errorf("%s: %s has no id", doc.Source, doc.Kind)
+		}
+	}
+	return docs, nil
+}
+
+func decode(data []byte, source string, defaultKind backup.Kind) ([]*Document, error) {
+	raw, err := splitDocuments(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", source, err)
+	}
+
+	docs := make([]*Document, 0, len(raw))
+	for i, doc := range raw {
+		name := fmt.Sprintf("%s#%d", source, i+1)
+		if len(raw) == 1 {
+			name = source
+		}
+
+		d, err := decodeDocument(doc, defaultKind)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		d.Source = name
+		docs = append(docs, d)
+	}
+	return docs, nil
+}
+
+// DecodeNode reads a manifest holding a single node, which may be incomplete.
+func DecodeNode(data []byte, source string) (*types.Node, error) {
+	docs, err := decode(data, source, backup.KindNode)
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) != 1 {
+		return nil, fmt.Errorf("%s: expected a single node, found %d documents", source, len(docs))
+	}
+
+	node, ok := docs[0].Object.(*types.Node)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a node, found a %s", source, docs[0].Kind)
+	}
+	return node, nil
+}
+
+// splitDocuments parses the documents in a manifest into generic maps.
+func splitDocuments(data []byte) ([]map[string]interface{}, error) {
+	docs := []map[string]interface{}{}
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("{")) || bytes.HasPrefix(trimmed, []byte("[")) {
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+		for {
+			var value interface{}
+			err := dec.Decode(&value)
+			if err == io.EOF {
+				return docs, nil
+			} else if err != nil {
+				return nil, err
+			}
+			docs, err = appendDocuments(docs, value)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var value interface{}
+		err := dec.Decode(&value)
+		if err == io.EOF {
+			return docs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			// empty document
+			continue
+		}
+
+		generic, err := jsonCompatible(value)
+		if err != nil {
+			return nil, err
+		}
+		docs, err = appendDocuments(docs, generic)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// appendDocuments adds a document, or every element of a list of documents.
+func appendDocuments(docs []map[string]interface{}, value interface{}) ([]map[string]interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return append(docs, v), nil
+	case []interface{}:
+		for _, element := range v {
+			var err error
+			docs, err = appendDocuments(docs, element)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return docs, nil
+	}
+	return nil, fmt.Errorf("expected an object, found %T", value)
+}
+
+// jsonCompatible converts the maps decoded from YAML, which may have keys of
+// any type, to maps with string keys.
+func jsonCompatible(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, child := range v {
+			converted, err := jsonCompatible(child)
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprintf("%v", key)] = converted
+		}
+		return m, nil
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, child := range v {
+			converted, err := jsonCompatible(child)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = converted
+		}
+		return l, nil
+	}
+	return value, nil
+}
+
+func decodeDocument(doc map[string]interface{}, defaultKind backup.Kind) (*Document, error) {
+	kind := defaultKind
+	for key, value := range doc {
+		if !strings.EqualFold(key, "kind") {
+			continue
+		}
+		name, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("kind must be a string")
+		}
+		parsed, err := backup.ParseKind(strings.ToLower(name))
+		if err != nil {
+			return nil, err
+		}
+		kind = parsed
+		delete(doc, key)
+	}
+
+	var obj backup.Object
+	switch kind {
+	case backup.KindNode:
+		obj = &types.Node{}
+	case backup.KindSystem:
+		obj = &types.System{}
+	case backup.KindNetwork:
+		obj = &types.Network{}
+	case "":
+		return nil, fmt.Errorf("kind is required")
+	default:
+		return nil, fmt.Errorf("%s objects can't be read from manifests", kind)
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal %s: %v", kind, err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(obj)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", kind, err)
+	}
+	return &Document{Kind: kind, Object: obj}, nil
+}
+
+// Encode writes an object as a YAML manifest document.  LastUpdated is left
+// out as manifests don't carry timestamps.
+func Encode(obj backup.Object) ([]byte, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal %s: %v", obj.ID(), err)
+	}
+
+	var doc yaml.MapSlice
+	err = yaml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert %s to yaml: %v", obj.ID(), err)
+	}
+
+	fields := make(yaml.MapSlice, 0, len(doc))
+	for _, field := range doc {
+		if field.Key != "LastUpdated" {
+			fields = append(fields, field)
+		}
+	}
+	return yaml.Marshal(fields)
+}
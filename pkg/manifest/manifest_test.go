@@ -0,0 +1,113 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PolarGeospatialCenter/inventory-cli/pkg/backup"
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+func TestDecode(t *testing.T) {
+	yamlManifest := `
+kind: node
+inventoryid: tst-0001
+system: tst
+rack: aa01
+bottomu: 12
+networks:
+  prod:
+    nics: ["00:11:22:33:44:55"]
+---
+---
+kind: System
+name: test
+shortname: tst
+roles: [compute]
+---
+- kind: network
+  name: prod
+- name: mgmt
+  kind: network
+`
+	docs, err := Decode([]byte(yamlManifest), "test.yaml", "")
+	if err != nil {
+		t.Fatalf("unable to decode manifest: %v", err)
+	}
+	if len(docs) != 4 {
+		t.Fatalf("expected 4 documents, got %d", len(docs))
+	}
+
+	node, ok := docs[0].Object.(*types.Node)
+	if !ok {
+		t.Fatalf("expected a node, got %T", docs[0].Object)
+	}
+	if node.Rack != "aa01" || node.BottomU != 12 || node.Networks["prod"].NICs[0].String() != "00:11:22:33:44:55" {
+		t.Errorf("node decoded incorrectly: %v", node)
+	}
+	if docs[1].Kind != backup.KindSystem || docs[1].Object.ID() != "tst" || docs[1].Source != "test.yaml#2" {
+		t.Errorf("system decoded incorrectly: %v", docs[1])
+	}
+	if docs[3].Kind != backup.KindNetwork || docs[3].Object.ID() != "mgmt" {
+		t.Errorf("network decoded incorrectly: %v", docs[3])
+	}
+
+	jsonManifest := `{"kind": "network", "name": "prod"} {"kind": "system", "name": "tst"}`
+	docs, err = Decode([]byte(jsonManifest), "test.json", "")
+	if err != nil || len(docs) != 2 {
+		t.Errorf("unable to decode json manifest: %v", err)
+	}
+
+	for _, invalid := range []string{
+		"inventoryid: tst-0001",
+		"kind: node\nsystem: tst",
+		"kind: node\ninventoryid: tst-0001\nserial: 1234",
+		"kind: ipreservation\nip: 10.0.0.1",
+		"kind: node\ninventoryid: tst-0001\nnetworks:\n  prod:\n    nics: [zz:11]",
+		"- 1",
+	} {
+		if _, err := Decode([]byte(invalid), "invalid.yaml", ""); err == nil {
+			t.Errorf("expected an error decoding %q", invalid)
+		}
+	}
+}
+
+func TestDecodeNode(t *testing.T) {
+	node, err := DecodeNode([]byte("system: tst\nrole: compute\n"), "node.yaml")
+	if err != nil {
+		t.Fatalf("unable to decode node: %v", err)
+	}
+	if node.System != "tst" || node.Role != "compute" || node.InventoryID != "" {
+		t.Errorf("node decoded incorrectly: %v", node)
+	}
+
+	if _, err := DecodeNode([]byte("kind: system\nname: tst\n"), "node.yaml"); err == nil {
+		t.Errorf("expected an error decoding a system as a node")
+	}
+}
+
+func TestEncode(t *testing.T) {
+	node, err := DecodeNode([]byte("inventoryid: tst-0001\nsystem: tst\nrack: aa01\nnetworks:\n  prod:\n    nics: [\"00:11:22:33:44:55\"]\n"), "node.yaml")
+	if err != nil {
+		t.Fatalf("unable to decode node: %v", err)
+	}
+	node.SetTimestamp(time.Now())
+
+	data, err := Encode(node)
+	if err != nil {
+		t.Fatalf("unable to encode node: %v", err)
+	}
+	if strings.Contains(string(data), "LastUpdated") {
+		t.Errorf("encoded node includes its timestamp:\n%s", data)
+	}
+
+	decoded, err := DecodeNode(data, "encoded.yaml")
+	if err != nil {
+		t.Fatalf("unable to decode encoded node: %v", err)
+	}
+	decoded.LastUpdated = node.LastUpdated
+	if changes, _ := backup.Diff(node, decoded); len(changes) != 0 {
+		t.Errorf("node changed after encoding: %v", changes)
+	}
+}
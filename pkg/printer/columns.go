@@ -0,0 +1,104 @@
+package printer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+// Column is a field of a node that can be shown in a table.
+type Column struct {
+	Name  string
+	Value func(node *types.Node) string
+}
+
+func location(node *types.Node) *types.ChassisLocation {
+	if node.ChassisLocation == nil {
+		return &types.ChassisLocation{}
+	}
+	return node.ChassisLocation
+}
+
+// NodeColumns lists every column available for nodes.
+var NodeColumns = []Column{
+	{"id", func(n *types.Node) string { return n.ID() }},
+	{"hostname", func(n *types.Node) string { return n.Hostname() }},
+	{"system", func(n *types.Node) string { return n.System }},
+	{"role", func(n *types.Node) string { return n.Role }},
+	{"environment", func(n *types.Node) string { return n.Environment }},
+	{"location", func(n *types.Node) string { return n.Location() }},
+	{"building", func(n *types.Node) string { return location(n).Building }},
+	{"room", func(n *types.Node) string { return location(n).Room }},
+	{"rack", func(n *types.Node) string { return location(n).Rack }},
+	{"u", func(n *types.Node) string {
+		if n.ChassisLocation == nil {
+			return ""
+		}
+		return fmt.Sprintf("%d", n.BottomU)
+	}},
+	{"subindex", func(n *types.Node) string { return n.ChassisSubIndex }},
+	{"nics", func(n *types.Node) string { return fmt.Sprintf("%d", nicCount(n)) }},
+	{"networks", func(n *types.Node) string { return strings.Join(networkNames(n), ",") }},
+	{"lastupdated", func(n *types.Node) string {
+		if n.LastUpdated.IsZero() {
+			return ""
+		}
+		return n.LastUpdated.Format(time.RFC3339)
+	}},
+}
+
+// DefaultNodeColumns are shown by the table format.
+var DefaultNodeColumns = []string{"id", "hostname"}
+
+// WideNodeColumns are shown by the wide format.
+var WideNodeColumns = []string{"id", "hostname", "system", "role", "environment", "location", "nics"}
+
+func nicCount(node *types.Node) int {
+	var count int
+	for _, iface := range node.Networks {
+		if iface != nil {
+			count += len(iface.NICs)
+		}
+	}
+	return count
+}
+
+func networkNames(node *types.Node) []string {
+	names := make([]string, 0, len(node.Networks))
+	for name := range node.Networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// nodeColumns looks up columns by name.
+func nodeColumns(names []string) ([]Column, error) {
+	columns := make([]Column, 0, len(names))
+	for _, name := range names {
+		var found bool
+		for _, column := range NodeColumns {
+			if column.Name == strings.ToLower(strings.TrimSpace(name)) {
+				columns = append(columns, column)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown column '%s', must be one of %s", name, strings.Join(ColumnNames(), ", "))
+		}
+	}
+	return columns, nil
+}
+
+// ColumnNames returns the names of all node columns.
+func ColumnNames() []string {
+	names := make([]string, 0, len(NodeColumns))
+	for _, column := range NodeColumns {
+		names = append(names, column.Name)
+	}
+	return names
+}
@@ -0,0 +1,228 @@
+package printer
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONPath is a parsed jsonpath template.  It supports the subset of kubectl's
+// jsonpath needed to pick fields from objects: text outside braces is printed
+// as is, and each {expression} is a path made of .field, ['field'], [index]
+// and [*] steps, optionally starting with $, or a quoted string literal.
+type JSONPath struct {
+	segments []segment
+}
+
+// segment is either literal text or a path.
+type segment struct {
+	text  string
+	steps []step
+	path  bool
+}
+
+type step struct {
+	field    string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// ParseJSONPath parses a jsonpath template.  A template without braces is
+// treated as a single expression.
+func ParseJSONPath(template string) (*JSONPath, error) {
+	if !strings.Contains(template, "{") {
+		template = "{" + template + "}"
+	}
+
+	path := &JSONPath{}
+	for len(template) > 0 {
+		start := strings.Index(template, "{")
+		if start < 0 {
+			path.segments = append(path.segments, segment{text: template})
+			break
+		}
+		if start > 0 {
+			path.segments = append(path.segments, segment{text: template[:start]})
+		}
+
+		end := strings.Index(template[start:], "}")
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed expression in jsonpath '%s'", template)
+		}
+		expr := strings.TrimSpace(template[start+1 : start+end])
+		template = template[start+end+1:]
+
+		if strings.HasPrefix(expr, `"`) || strings.HasPrefix(expr, "'") {
+			text, err := unquote(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string literal %s in jsonpath: %v", expr, err)
+			}
+			path.segments = append(path.segments, segment{text: text})
+			continue
+		}
+
+		steps, err := parseSteps(expr)
+		if err != nil {
+			return nil, err
+		}
+		path.segments = append(path.segments, segment{steps: steps, path: true})
+	}
+	return path, nil
+}
+
+func unquote(literal string) (string, error) {
+	if strings.HasPrefix(literal, "'") {
+		literal = `"` + strings.Replace(strings.Trim(literal, "'"), `"`, `\"`, -1) + `"`
+	}
+	return strconv.Unquote(literal)
+}
+
+func parseSteps(expr string) ([]step, error) {
+	steps := []step{}
+	rest := strings.TrimPrefix(expr, "$")
+	for len(rest) > 0 {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			name := rest[:end]
+			rest = rest[end:]
+			if name == "*" {
+				steps = append(steps, step{wildcard: true})
+			} else if name != "" {
+				steps = append(steps, step{field: name})
+			}
+		case '[':
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed [ in jsonpath expression '%s'", expr)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case inner == "*":
+				steps = append(steps, step{wildcard: true})
+			case strings.HasPrefix(inner, "'") || strings.HasPrefix(inner, `"`):
+				field, err := unquote(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid field %s in jsonpath expression '%s'", inner, expr)
+				}
+				steps = append(steps, step{field: field})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid index [%s] in jsonpath expression '%s'", inner, expr)
+				}
+				steps = append(steps, step{index: index, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("unexpected '%c' in jsonpath expression '%s'", rest[0], expr)
+		}
+	}
+	return steps, nil
+}
+
+// Execute evaluates the template against a generic json document.  Paths
+// that match several values print them separated by spaces, missing fields
+// print nothing.
+func (p *JSONPath) Execute(doc interface{}) (string, error) {
+	out := &strings.Builder{}
+	for _, seg := range p.segments {
+		if !seg.path {
+			out.WriteString(seg.text)
+			continue
+		}
+
+		values := []interface{}{doc}
+		for _, s := range seg.steps {
+			values = s.apply(values)
+		}
+
+		formatted := make([]string, 0, len(values))
+		for _, value := range values {
+			text, err := formatJSONValue(value)
+			if err != nil {
+				return "", err
+			}
+			formatted = append(formatted, text)
+		}
+		out.WriteString(strings.Join(formatted, " "))
+	}
+	return out.String(), nil
+}
+
+func (s step) apply(values []interface{}) []interface{} {
+	results := []interface{}{}
+	for _, value := range values {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if s.wildcard {
+				for _, key := range sortedMapKeys(v) {
+					results = append(results, v[key])
+				}
+			} else if !s.isIndex {
+				if child, ok := lookupField(v, s.field); ok {
+					results = append(results, child)
+				}
+			}
+		case []interface{}:
+			switch {
+			case s.wildcard:
+				results = append(results, v...)
+			case s.isIndex:
+				index := s.index
+				if index < 0 {
+					index += len(v)
+				}
+				if index >= 0 && index < len(v) {
+					results = append(results, v[index])
+				}
+			}
+		}
+	}
+	return results
+}
+
+// lookupField finds a field by its exact name, falling back to a case
+// insensitive match.
+func lookupField(m map[string]interface{}, name string) (interface{}, bool) {
+	if value, ok := m[name]; ok {
+		return value, true
+	}
+	for _, key := range sortedMapKeys(m) {
+		if strings.EqualFold(key, name) {
+			return m[key], true
+		}
+	}
+	return nil, false
+}
+
+func formatJSONValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+
+	txt, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal value: %v", err)
+	}
+	return string(txt), nil
+}
+
+func sortedMapKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
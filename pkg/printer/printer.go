@@ -0,0 +1,200 @@
+// Package printer formats inventory objects for the command line.
+package printer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/PolarGeospatialCenter/inventory-cli/pkg/backup"
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Formats lists the supported output formats.  list is the original id and
+// hostname format of node list.  template and jsonpath take an argument after
+// an equals sign.
+var Formats = []string{"list", "table", "wide", "json", "yaml", "csv", "name", "template=<go-template>", "jsonpath=<expr>"}
+
+// NodePrinter writes a list of nodes.
+type NodePrinter interface {
+	PrintNodes(w io.Writer, nodes []*types.Node) error
+}
+
+// NodePrinterFunc adapts a function to a NodePrinter.
+type NodePrinterFunc func(w io.Writer, nodes []*types.Node) error
+
+// PrintNodes calls f.
+func (f NodePrinterFunc) PrintNodes(w io.Writer, nodes []*types.Node) error {
+	return f(w, nodes)
+}
+
+// NewNodePrinter returns a printer for an output format.  columns overrides
+// the columns shown by the table, wide and csv formats.
+func NewNodePrinter(format string, columns []string) (NodePrinter, error) {
+	name, arg := format, ""
+	if i := strings.Index(format, "="); i >= 0 {
+		name, arg = format[:i], format[i+1:]
+	}
+
+	if len(columns) > 0 && name != "table" && name != "wide" && name != "csv" {
+		return nil, fmt.Errorf("columns can only be chosen for the table, wide and csv formats")
+	}
+
+	switch name {
+	case "list":
+		return NodePrinterFunc(printList), nil
+	case "table", "wide", "csv":
+		if len(columns) == 0 {
+			columns = DefaultNodeColumns
+			if name == "wide" || name == "csv" {
+				columns = WideNodeColumns
+			}
+		}
+		cols, err := nodeColumns(columns)
+		if err != nil {
+			return nil, err
+		}
+		if name == "csv" {
+			return csvPrinter(cols), nil
+		}
+		return tablePrinter(cols), nil
+	case "json":
+		return NodePrinterFunc(printJSON), nil
+	case "yaml":
+		return NodePrinterFunc(printYAML), nil
+	case "name":
+		return NodePrinterFunc(printNames), nil
+	case "template":
+		tmpl, err := template.New("node").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse template: %v", err)
+		}
+		return templatePrinter(tmpl), nil
+	case "jsonpath":
+		path, err := ParseJSONPath(arg)
+		if err != nil {
+			return nil, err
+		}
+		return jsonPathPrinter(path), nil
+	}
+	return nil, fmt.Errorf("unknown output format '%s', must be one of %s", format, strings.Join(Formats, ", "))
+}
+
+func tablePrinter(columns []Column) NodePrinter {
+	return NodePrinterFunc(func(w io.Writer, nodes []*types.Node) error {
+		tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = strings.ToUpper(column.Name)
+		}
+		fmt.Fprintf(tw, "%s\n", strings.Join(header, "\t"))
+
+		for _, node := range nodes {
+			row := make([]string, len(columns))
+			for i, column := range columns {
+				row[i] = column.Value(node)
+			}
+			fmt.Fprintf(tw, "%s\n", strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	})
+}
+
+func csvPrinter(columns []Column) NodePrinter {
+	return NodePrinterFunc(func(w io.Writer, nodes []*types.Node) error {
+		cw := csv.NewWriter(w)
+		header := make([]string, len(columns))
+		for i, column := range columns {
+			header[i] = column.Name
+		}
+		cw.Write(header)
+
+		for _, node := range nodes {
+			row := make([]string, len(columns))
+			for i, column := range columns {
+				row[i] = column.Value(node)
+			}
+			cw.Write(row)
+		}
+		cw.Flush()
+		return cw.Error()
+	})
+}
+
+func printJSON(w io.Writer, nodes []*types.Node) error {
+	txt, err := json.MarshalIndent(nodes, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal nodes: %v", err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", txt)
+	return err
+}
+
+func printYAML(w io.Writer, nodes []*types.Node) error {
+	doc, err := backup.ToGeneric(nodes)
+	if err != nil {
+		return err
+	}
+
+	txt, err := yaml.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("unable to marshal nodes: %v", err)
+	}
+	_, err = w.Write(txt)
+	return err
+}
+
+func printList(w io.Writer, nodes []*types.Node) error {
+	for _, node := range nodes {
+		if _, err := fmt.Fprintf(w, "%25s - %s\n", node.ID(), node.Hostname()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printNames(w io.Writer, nodes []*types.Node) error {
+	for _, node := range nodes {
+		if _, err := fmt.Fprintf(w, "node/%s\n", node.ID()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// templatePrinter executes a template for every node, each followed by a newline.
+func templatePrinter(tmpl *template.Template) NodePrinter {
+	return NodePrinterFunc(func(w io.Writer, nodes []*types.Node) error {
+		for _, node := range nodes {
+			if err := tmpl.Execute(w, node); err != nil {
+				return fmt.Errorf("unable to execute template for %s: %v", node.ID(), err)
+			}
+			fmt.Fprintf(w, "\n")
+		}
+		return nil
+	})
+}
+
+// jsonPathPrinter evaluates a jsonpath expression against every node, each
+// followed by a newline.
+func jsonPathPrinter(path *JSONPath) NodePrinter {
+	return NodePrinterFunc(func(w io.Writer, nodes []*types.Node) error {
+		for _, node := range nodes {
+			doc, err := backup.ToGeneric(node)
+			if err != nil {
+				return err
+			}
+			out, err := path.Execute(doc)
+			if err != nil {
+				return fmt.Errorf("unable to evaluate jsonpath for %s: %v", node.ID(), err)
+			}
+			fmt.Fprintf(w, "%s\n", out)
+		}
+		return nil
+	})
+}
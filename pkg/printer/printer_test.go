@@ -0,0 +1,81 @@
+package printer
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+func testNodes(t *testing.T) []*types.Node {
+	mac, err := net.ParseMAC("00:11:22:33:44:55")
+	if err != nil {
+		t.Fatalf("unable to parse mac: %v", err)
+	}
+	return []*types.Node{
+		{
+			InventoryID:     "tst-0001",
+			System:          "tst",
+			Role:            "compute",
+			Environment:     "prod",
+			ChassisLocation: &types.ChassisLocation{Building: "bldg", Room: "room", Rack: "aa01", BottomU: 12},
+			Networks:        types.NICInfoMap{"prod": &types.NetworkInterface{NICs: []net.HardwareAddr{mac}}},
+		},
+		{InventoryID: "tst-0002", Role: "storage, large"},
+	}
+}
+
+func TestNodePrinter(t *testing.T) {
+	tests := []struct {
+		format   string
+		columns  []string
+		expected string
+	}{
+		{"list", nil, "                 tst-0001 - tst-aa01-12\n                 tst-0002 - tst-0002\n"},
+		{"table", nil, "ID        HOSTNAME\ntst-0001  tst-aa01-12\ntst-0002  tst-0002\n"},
+		{"table", []string{"id", "Rack", "u"}, "ID        RACK  U\ntst-0001  aa01  12\ntst-0002        \n"},
+		{"wide", nil, "ID        HOSTNAME     SYSTEM  ROLE            ENVIRONMENT  LOCATION  NICS\n" +
+			"tst-0001  tst-aa01-12  tst     compute         prod         aa01-12   1\n" +
+			"tst-0002  tst-0002             storage, large                         0\n"},
+		{"csv", []string{"id", "role"}, "id,role\ntst-0001,compute\ntst-0002,\"storage, large\"\n"},
+		{"name", nil, "node/tst-0001\nnode/tst-0002\n"},
+		{"template={{.InventoryID}} {{.Role}}", nil, "tst-0001 compute\ntst-0002 storage, large\n"},
+		{`jsonpath={.InventoryID}{"\t"}{.Rack}`, nil, "tst-0001\taa01\ntst-0002\t\n"},
+		{"jsonpath=.networks.prod.nics[0]", nil, "00:11:22:33:44:55\n\n"},
+		{"yaml", nil, ""},
+		{"json", nil, ""},
+	}
+
+	for _, test := range tests {
+		p, err := NewNodePrinter(test.format, test.columns)
+		if err != nil {
+			t.Errorf("%s: unable to create printer: %v", test.format, err)
+			continue
+		}
+
+		out := &bytes.Buffer{}
+		err = p.PrintNodes(out, testNodes(t))
+		if err != nil {
+			t.Errorf("%s: unable to print nodes: %v", test.format, err)
+		}
+		if test.expected != "" && out.String() != test.expected {
+			t.Errorf("%s: unexpected output:\n%s\nexpected:\n%s", test.format, out.String(), test.expected)
+		}
+		if out.Len() == 0 {
+			t.Errorf("%s: no output", test.format)
+		}
+	}
+
+	for _, format := range []string{"xml", "template={{.Missing", "jsonpath={.Role"} {
+		if _, err := NewNodePrinter(format, nil); err == nil {
+			t.Errorf("expected an error for format %s", format)
+		}
+	}
+	if _, err := NewNodePrinter("table", []string{"serial"}); err == nil {
+		t.Errorf("expected an error for an unknown column")
+	}
+	if _, err := NewNodePrinter("json", []string{"id"}); err == nil {
+		t.Errorf("expected an error choosing columns for json")
+	}
+}
@@ -0,0 +1,465 @@
+// Package selector implements a label selector style query language for nodes.
+//
+// A selector is a comma separated list of requirements that must all match:
+//
+//	environment=prod,rack in (aa01,aa02),metadata.serial_console exists
+//
+// Requirements compare a key with =, ==, !=, in (...), notin (...), exists,
+// !exists, the regular expression match ~= or, for u and lastupdated, the
+// ordering operators <, <=, > and >=.  Values compared with =, !=, in and
+// notin may be glob patterns and for u and lastupdated a range written as
+// low..high.  Timestamps are RFC3339 times, dates or durations relative to
+// now such as -24h.
+package selector
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+// Operator compares the value of a key with the values of a requirement.
+type Operator string
+
+const (
+	Equals       Operator = "="
+	NotEquals    Operator = "!="
+	In           Operator = "in"
+	NotIn        Operator = "notin"
+	Exists       Operator = "exists"
+	DoesNotExist Operator = "!exists"
+	Matches      Operator = "~="
+	LessThan     Operator = "<"
+	LessEqual    Operator = "<="
+	GreaterThan  Operator = ">"
+	GreaterEqual Operator = ">="
+)
+
+// valueType determines how the values of a key are compared.
+type valueType int
+
+const (
+	stringValue valueType = iota
+	numberValue
+	timeValue
+)
+
+// keys lists the keys that can be selected on, except metadata.<key>.
+var keys = map[string]valueType{
+	"id":          stringValue,
+	"hostname":    stringValue,
+	"system":      stringValue,
+	"role":        stringValue,
+	"environment": stringValue,
+	"building":    stringValue,
+	"room":        stringValue,
+	"rack":        stringValue,
+	"u":           numberValue,
+	"subindex":    stringValue,
+	"network":     stringValue,
+	"lastupdated": timeValue,
+}
+
+var aliases = map[string]string{
+	"inventoryid": "id",
+	"bottomu":     "u",
+	"networks":    "network",
+}
+
+// Keys returns the names of the keys that can be selected on.
+func Keys() []string {
+	names := make([]string, 0, len(keys)+1)
+	for key := range keys {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	return append(names, "metadata.<key>")
+}
+
+// Requirement is a single condition of a selector.
+type Requirement struct {
+	Key      string
+	Operator Operator
+	Values   []string
+
+	valueType valueType
+	regexp    *regexp.Regexp
+	// bounds holds the parsed values of ordered keys, ranges use two bounds
+	bounds [][2]float64
+}
+
+// Selector matches nodes that meet all of its requirements.
+type Selector []*Requirement
+
+var (
+	setPattern     = regexp.MustCompile(`^([^\s=!<>~()]+)\s+(in|notin)\s*\((.*)\)$`)
+	existsPattern  = regexp.MustCompile(`^([^\s=!<>~()]+)\s+(exists|!exists)$`)
+	comparePattern = regexp.MustCompile(`^([^\s=!<>~()]+)\s*(==|!=|>=|<=|~=|=|>|<)\s*(.*)$`)
+	keyPattern     = regexp.MustCompile(`^(!?)([^\s=!<>~()]+)$`)
+)
+
+// Parse parses a selector.  An empty selector matches every node.
+func Parse(selector string) (Selector, error) {
+	s := Selector{}
+	for _, term := range splitTerms(selector) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		r, err := parseRequirement(term)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, r)
+	}
+	return s, nil
+}
+
+// splitTerms splits a selector on the commas that aren't inside parentheses.
+func splitTerms(selector string) []string {
+	terms := []string{}
+	var depth, start int
+	for i, c := range selector {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, selector[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(terms, selector[start:])
+}
+
+func parseRequirement(term string) (*Requirement, error) {
+	r := &Requirement{}
+	if m := setPattern.FindStringSubmatch(term); m != nil {
+		r.Key, r.Operator = m[1], Operator(m[2])
+		for _, value := range strings.Split(m[3], ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				r.Values = append(r.Values, value)
+			}
+		}
+		if len(r.Values) == 0 {
+			return nil, fmt.Errorf("%s requires at least one value in '%s'", r.Operator, term)
+		}
+	} else if m := existsPattern.FindStringSubmatch(term); m != nil {
+		r.Key, r.Operator = m[1], Operator(m[2])
+	} else if m := comparePattern.FindStringSubmatch(term); m != nil {
+		r.Key, r.Operator, r.Values = m[1], Operator(m[2]), []string{strings.TrimSpace(m[3])}
+		if r.Operator == "==" {
+			r.Operator = Equals
+		}
+	} else if m := keyPattern.FindStringSubmatch(term); m != nil {
+		r.Key, r.Operator = m[2], Exists
+		if m[1] == "!" {
+			r.Operator = DoesNotExist
+		}
+	} else {
+		return nil, fmt.Errorf("unable to parse requirement '%s'", term)
+	}
+
+	err := r.validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid requirement '%s': %v", term, err)
+	}
+	return r, nil
+}
+
+// validate normalizes the key and parses the values of a requirement.
+func (r *Requirement) validate() error {
+	r.Key = strings.ToLower(r.Key)
+	if alias, ok := aliases[r.Key]; ok {
+		r.Key = alias
+	}
+
+	if strings.HasPrefix(r.Key, "metadata.") {
+		r.valueType = stringValue
+	} else if t, ok := keys[r.Key]; ok {
+		r.valueType = t
+	} else {
+		return fmt.Errorf("unknown key '%s', must be one of %s", r.Key, strings.Join(Keys(), ", "))
+	}
+
+	switch r.Operator {
+	case Exists, DoesNotExist:
+		return nil
+	case Matches:
+		re, err := regexp.Compile(r.Values[0])
+		if err != nil {
+			return err
+		}
+		r.regexp = re
+		return nil
+	case LessThan, LessEqual, GreaterThan, GreaterEqual:
+		if r.valueType == stringValue {
+			return fmt.Errorf("%s can only be compared with = or !=, not %s", r.Key, r.Operator)
+		}
+	}
+
+	for _, value := range r.Values {
+		if r.valueType == stringValue {
+			if _, err := path.Match(value, ""); err != nil {
+				return fmt.Errorf("invalid pattern '%s': %v", value, err)
+			}
+			continue
+		}
+
+		low, high := value, value
+		if parts := strings.SplitN(value, "..", 2); len(parts) == 2 && (r.Operator == Equals || r.Operator == NotEquals || r.Operator == In || r.Operator == NotIn) {
+			low, high = parts[0], parts[1]
+		}
+		lowValue, err := r.parseOrdered(low)
+		if err != nil {
+			return err
+		}
+		highValue, err := r.parseOrdered(high)
+		if err != nil {
+			return err
+		}
+		r.bounds = append(r.bounds, [2]float64{lowValue, highValue})
+	}
+	return nil
+}
+
+// parseOrdered parses the value of a number or time key.  Times are
+// converted to unix seconds.
+func (r *Requirement) parseOrdered(value string) (float64, error) {
+	if r.valueType == numberValue {
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%s must be a number, not '%s'", r.Key, value)
+		}
+		return n, nil
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		return float64(time.Now().Add(d).Unix()), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return float64(t.Unix()), nil
+		}
+	}
+	return 0, fmt.Errorf("%s must be an RFC3339 time, a date or a duration relative to now, not '%s'", r.Key, value)
+}
+
+// String returns the requirement in selector syntax.
+func (r *Requirement) String() string {
+	switch r.Operator {
+	case Exists:
+		return r.Key + " exists"
+	case DoesNotExist:
+		return r.Key + " !exists"
+	case In, NotIn:
+		return fmt.Sprintf("%s %s (%s)", r.Key, r.Operator, strings.Join(r.Values, ","))
+	}
+	return fmt.Sprintf("%s%s%s", r.Key, r.Operator, r.Values[0])
+}
+
+// String returns the selector in selector syntax.
+func (s Selector) String() string {
+	terms := make([]string, len(s))
+	for i, r := range s {
+		terms[i] = r.String()
+	}
+	return strings.Join(terms, ",")
+}
+
+// Empty returns true if the selector matches everything.
+func (s Selector) Empty() bool {
+	return len(s) == 0
+}
+
+// Matches returns true if the node meets every requirement.
+func (s Selector) Matches(node *types.Node) bool {
+	for _, r := range s {
+		if !r.Matches(node) {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches returns true if the node meets the requirement.
+func (r *Requirement) Matches(node *types.Node) bool {
+	values := fieldValues(node, r.Key)
+	switch r.Operator {
+	case Exists:
+		return len(values) > 0
+	case DoesNotExist:
+		return len(values) == 0
+	case Equals, In:
+		return r.matchesAny(values)
+	case NotEquals, NotIn:
+		return !r.matchesAny(values)
+	case Matches:
+		for _, value := range values {
+			if r.regexp.MatchString(value) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, value := range values {
+		n, ok := parseNumber(value)
+		if !ok {
+			continue
+		}
+		bound := r.bounds[0][0]
+		switch {
+		case r.Operator == LessThan && n < bound,
+			r.Operator == LessEqual && n <= bound,
+			r.Operator == GreaterThan && n > bound,
+			r.Operator == GreaterEqual && n >= bound:
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAny returns true if any of the values match any pattern or range of
+// the requirement.
+func (r *Requirement) matchesAny(values []string) bool {
+	for _, value := range values {
+		if r.valueType == stringValue {
+			for _, pattern := range r.Values {
+				if ok, _ := path.Match(pattern, value); ok {
+					return true
+				}
+			}
+			continue
+		}
+
+		n, ok := parseNumber(value)
+		if !ok {
+			continue
+		}
+		for _, bound := range r.bounds {
+			if n >= bound[0] && n <= bound[1] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func parseNumber(value string) (float64, bool) {
+	n, err := strconv.ParseFloat(value, 64)
+	return n, err == nil
+}
+
+// fieldValues returns the values of a key for a node, no values means the key
+// doesn't exist.  Numbers and timestamps are formatted as decimal numbers,
+// timestamps in unix seconds.
+func fieldValues(node *types.Node, key string) []string {
+	single := func(value string) []string {
+		if value == "" {
+			return nil
+		}
+		return []string{value}
+	}
+
+	switch key {
+	case "id":
+		return single(node.ID())
+	case "hostname":
+		return single(node.Hostname())
+	case "system":
+		return single(node.System)
+	case "role":
+		return single(node.Role)
+	case "environment":
+		return single(node.Environment)
+	case "subindex":
+		return single(node.ChassisSubIndex)
+	case "network":
+		networks := make([]string, 0, len(node.Networks))
+		for name := range node.Networks {
+			networks = append(networks, name)
+		}
+		sort.Strings(networks)
+		return networks
+	case "lastupdated":
+		if node.LastUpdated.IsZero() {
+			return nil
+		}
+		return []string{strconv.FormatInt(node.LastUpdated.Unix(), 10)}
+	}
+
+	if node.ChassisLocation != nil {
+		switch key {
+		case "building":
+			return single(node.Building)
+		case "room":
+			return single(node.Room)
+		case "rack":
+			return single(node.Rack)
+		case "u":
+			return []string{strconv.FormatUint(uint64(node.BottomU), 10)}
+		}
+	}
+
+	if strings.HasPrefix(key, "metadata.") {
+		return metadataValues(node.Metadata, strings.TrimPrefix(key, "metadata."))
+	}
+	return nil
+}
+
+// metadataValues looks up a dotted key in nested metadata.  Lists match if
+// any of their elements match.
+func metadataValues(metadata map[string]interface{}, key string) []string {
+	var value interface{} = metadata
+	for _, part := range strings.Split(key, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			if typed, isMetadata := value.(types.Metadata); isMetadata {
+				m, ok = typed, true
+			}
+		}
+		if !ok {
+			return nil
+		}
+		if value, ok = lookupKey(m, part); !ok {
+			return nil
+		}
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return []string{""}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, element := range v {
+			values = append(values, fmt.Sprintf("%v", element))
+		}
+		return values
+	}
+	return []string{fmt.Sprintf("%v", value)}
+}
+
+// lookupKey finds a metadata key by its exact name, falling back to a case
+// insensitive match, since keys in selectors are lower cased.
+func lookupKey(m map[string]interface{}, key string) (interface{}, bool) {
+	if value, ok := m[key]; ok {
+		return value, true
+	}
+	for k, value := range m {
+		if strings.EqualFold(k, key) {
+			return value, true
+		}
+	}
+	return nil, false
+}
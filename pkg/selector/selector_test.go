@@ -0,0 +1,76 @@
+package selector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PolarGeospatialCenter/inventory/pkg/inventory/types"
+)
+
+func TestSelector(t *testing.T) {
+	node := &types.Node{
+		InventoryID:     "tst-0012",
+		System:          "tst",
+		Role:            "compute",
+		Environment:     "prod",
+		ChassisLocation: &types.ChassisLocation{Building: "bldg", Room: "room", Rack: "aa02", BottomU: 12},
+		Networks:        types.NICInfoMap{"prod": &types.NetworkInterface{}, "mgmt": &types.NetworkInterface{}},
+		Metadata:        types.Metadata{"serial_console": "ttyS1", "bmc": map[string]interface{}{"vendor": "dell"}, "tags": []interface{}{"gpu", "ib"}},
+		LastUpdated:     time.Now().Add(-time.Hour),
+	}
+
+	tests := []struct {
+		selector string
+		matches  bool
+	}{
+		{"", true},
+		{"environment=prod,rack in (aa01,aa02),metadata.serial_console exists", true},
+		{"environment=prod,rack in (aa01,aa03)", false},
+		{"environment!=prod", false},
+		{"role notin (storage)", true},
+		{"building==bldg,room=room", true},
+		{"u>=10,u<20", true},
+		{"u>12", false},
+		{"u=10..15", true},
+		{"u in (1..4,20..30)", false},
+		{"id=tst-00*", true},
+		{"id~=^tst-[0-9]+$", true},
+		{"inventoryid=other-*", false},
+		{"network=prod", true},
+		{"network in (ib)", false},
+		{"network!=ib", true},
+		{"metadata.serial_console=ttyS1", true},
+		{"metadata.serial_console=ttyS0", false},
+		{"metadata.bmc.vendor=dell", true},
+		{"metadata.tags=gpu", true},
+		{"metadata.ipmi exists", false},
+		{"metadata.ipmi !exists", true},
+		{"!metadata.ipmi", true},
+		{"subindex", false},
+		{"lastupdated>-24h", true},
+		{"lastupdated<2019-01-01", false},
+		{"lastupdated>=2019-01-01T00:00:00Z", true},
+	}
+
+	for _, test := range tests {
+		s, err := Parse(test.selector)
+		if err != nil {
+			t.Errorf("unable to parse '%s': %v", test.selector, err)
+			continue
+		}
+		if matches := s.Matches(node); matches != test.matches {
+			t.Errorf("expected '%s' matches=%t, got %t", test.selector, test.matches, matches)
+		}
+	}
+
+	for _, selector := range []string{"serial=1", "rack>aa01", "u=twelve", "lastupdated>yesterday", "rack in ()", "id~=(", "rack in (aa01"} {
+		if _, err := Parse(selector); err == nil {
+			t.Errorf("expected an error parsing '%s'", selector)
+		}
+	}
+
+	s, _ := Parse("environment==prod, rack in (aa01, aa02),!metadata.ipmi")
+	if s.String() != "environment=prod,rack in (aa01,aa02),metadata.ipmi !exists" {
+		t.Errorf("unexpected string for selector: %s", s)
+	}
+}